@@ -0,0 +1,174 @@
+package betting
+
+import (
+	"blackjack/counting"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Kelly - bet sizing by the Kelly criterion: at each true count, Fraction of
+// the bankroll times the edge over the variance of a round there. The edges and
+// variances come from a calibration run (see NewKelly).
+type Kelly struct {
+	Fraction float64 // 1 for full Kelly, e.g. 0.5 for half Kelly
+	Edge     [2*tableRange + 1]float64
+	Variance [2*tableRange + 1]float64
+	Rounds   [2*tableRange + 1]int
+}
+
+// kellyMinRounds - counts with fewer calibration rounds are sat out
+const kellyMinRounds = 1000
+
+// NewKelly reads the edge and variance at every true count from a calibration
+// run, best played flat so every count is sampled alike
+func NewKelly(calibration Result, fraction float64) *Kelly {
+	k := &Kelly{Fraction: fraction}
+	for i, row := range calibration.Counts {
+		k.Edge[i], k.Variance[i], k.Rounds[i] = row.Edge(), row.Variance(), row.Rounds
+	}
+	return k
+}
+
+// Share returns the share of the bankroll to bet at true count tc, 0 where the
+// edge is not positive or the count was sampled too little
+func (k *Kelly) Share(tc float64) float64 {
+	i := counting.Bucket(tc, tableRange) + tableRange
+	if k.Rounds[i] < kellyMinRounds || k.Edge[i] <= 0 || k.Variance[i] <= 0 {
+		return 0
+	}
+	return k.Fraction * k.Edge[i] / k.Variance[i]
+}
+
+// Print the Kelly table: edge, variance and bet by true count for a bankroll
+func (k *Kelly) Print(bankroll, min, max float64) {
+	fmt.Printf("%-6s %9s %9s %9s %10s %10s\n", "tc", "edge", "variance", "full", "full bet", "bet")
+	for i := range k.Edge {
+		if k.Rounds[i] == 0 {
+			continue
+		}
+		full := 0.0
+		if k.Rounds[i] >= kellyMinRounds && k.Edge[i] > 0 && k.Variance[i] > 0 {
+			full = k.Edge[i] / k.Variance[i]
+		}
+		bet := func(share float64) float64 {
+			bet := math.Floor(share*bankroll/min) * min
+			if bet < min {
+				return 0 // sat out
+			}
+			return math.Min(max, bet)
+		}
+		fmt.Printf("%-6s %+8.3f%% %9.4f %8.3f%% %10.2f %10.2f\n", countLabel(i), 100*k.Edge[i], k.Variance[i],
+			100*full, bet(full), bet(k.Fraction*full))
+	}
+}
+
+// SessionResult - outcome of many sessions, each from the same bankroll
+type SessionResult struct {
+	Bankroll     float64
+	Rounds       int // rounds dealt per session, unless ruined
+	HandsPerHour float64
+
+	Results   []float64 // net result of each session, sorted
+	Drawdowns []float64 // largest fall from a session high of each session, sorted
+	Doubled   []int     // rounds to double the bankroll of the sessions that did, sorted
+	Ruined    int       // sessions that could no longer make the table minimum
+
+	Dealt   int     // rounds dealt over all sessions
+	Won     float64 // net result over all sessions
+	Elapsed time.Duration
+}
+
+// Sessions plays sessions of rounds rounds with p, each starting from bankroll.
+// A session ends in ruin once the bankroll cannot make the table minimum.
+func Sessions(p *Player, bankroll float64, rounds, sessions int, handsPerHour float64) SessionResult {
+	startTime := time.Now()
+	res := SessionResult{Bankroll: bankroll, Rounds: rounds, HandsPerHour: handsPerHour}
+	for s := 0; s < sessions; s++ {
+		money, high, drawdown := bankroll, bankroll, 0.0
+		doubled := false
+		for i := 1; i <= rounds; i++ {
+			p.Bankroll = money
+			money += p.Next().Result
+			res.Dealt++
+
+			if money > high {
+				high = money
+			}
+			drawdown = math.Max(drawdown, high-money)
+			if !doubled && money >= 2*bankroll {
+				doubled = true
+				res.Doubled = append(res.Doubled, i)
+			}
+			if money < p.table.Min {
+				res.Ruined++
+				break
+			}
+		}
+		res.Results = append(res.Results, money-bankroll)
+		res.Drawdowns = append(res.Drawdowns, drawdown)
+		res.Won += money - bankroll
+	}
+	p.Bankroll = 0
+	sort.Float64s(res.Results)
+	sort.Float64s(res.Drawdowns)
+	sort.Ints(res.Doubled)
+	res.Elapsed = time.Since(startTime)
+	return res
+}
+
+// percentile returns the p-th percentile (0-100) of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	i := int(math.Round(p / 100 * float64(len(sorted)-1)))
+	return sorted[i]
+}
+
+// RiskOfRuin returns the share of sessions ruined
+func (res SessionResult) RiskOfRuin() float64 {
+	return float64(res.Ruined) / float64(len(res.Results))
+}
+
+// HoursToDouble returns the median hours of the sessions that doubled the
+// bankroll (false if none did) and the hours the average win takes to double it
+func (res SessionResult) HoursToDouble() (median float64, ok bool, expected float64) {
+	expected = math.Inf(1)
+	if res.Won > 0 {
+		expected = res.Bankroll / (res.Won / float64(res.Dealt)) / res.HandsPerHour
+	}
+	if len(res.Doubled) == 0 {
+		return 0, false, expected
+	}
+	return float64(res.Doubled[len(res.Doubled)/2]) / res.HandsPerHour, true, expected
+}
+
+// Print human format session summary
+func (res SessionResult) Print() {
+	n := len(res.Results)
+	fmt.Printf("Sessions: %d of %d rounds (%.1f hours) from a bankroll of %g (%s)\n", n, res.Rounds,
+		float64(res.Rounds)/res.HandsPerHour, res.Bankroll, res.Elapsed.Round(time.Millisecond))
+	fmt.Printf("Risk of ruin: %.2f%% (%d sessions)\n", 100*res.RiskOfRuin(), res.Ruined)
+	fmt.Printf("Mean result: %+.2f  per hour: %+.2f\n", res.Won/float64(n), res.Won/float64(res.Dealt)*res.HandsPerHour)
+
+	fmt.Println("Session results:")
+	for _, p := range []float64{1, 5, 10, 25, 50, 75, 90, 95, 99} {
+		fmt.Printf("  %2.0f%%  %+10.2f\n", p, percentile(res.Results, p))
+	}
+	fmt.Printf("Drawdown: median %.2f, worst %.2f\n", percentile(res.Drawdowns, 50), res.Drawdowns[n-1])
+
+	median, ok, expected := res.HoursToDouble()
+	switch {
+	case ok:
+		fmt.Printf("Doubled the bankroll: %.2f%% of sessions, median %.1f hours\n", 100*float64(len(res.Doubled))/float64(n), median)
+	default:
+		fmt.Println("Doubled the bankroll: no session")
+	}
+	if math.IsInf(expected, 1) {
+		fmt.Println("Hours to double at the average win: never")
+	} else {
+		fmt.Printf("Hours to double at the average win: %.1f\n", expected)
+	}
+}
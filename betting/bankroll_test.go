@@ -0,0 +1,136 @@
+package betting
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{-3, -1, 0, 2, 10}
+	tests := []struct {
+		p, want float64
+	}{
+		{0, -3},
+		{25, -1},
+		{50, 0},
+		{60, 0}, // the nearest rank
+		{70, 2},
+		{100, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile %g: %g, want %g", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); !math.IsNaN(got) {
+		t.Errorf("percentile of nothing: %g, want NaN", got)
+	}
+}
+
+func TestSessionStatistics(t *testing.T) {
+	tests := []struct {
+		name     string
+		res      SessionResult
+		ruin     float64
+		median   float64
+		doubled  bool
+		expected float64
+	}{
+		{"no session doubled", SessionResult{Bankroll: 1000, HandsPerHour: 100,
+			Results: []float64{-1000, -200, 300, 500}, Ruined: 1, Dealt: 1000, Won: 600},
+			0.25, 0, false, 1000 / (600.0 / 1000) / 100},
+		{"losing", SessionResult{Bankroll: 1000, HandsPerHour: 100,
+			Results: []float64{-1000, -1000}, Ruined: 2, Dealt: 100, Won: -2000},
+			1, 0, false, math.Inf(1)},
+		{"doubled", SessionResult{Bankroll: 1000, HandsPerHour: 50,
+			Results: []float64{1000, 1200, 1500}, Doubled: []int{100, 250, 400}, Dealt: 1500, Won: 3700},
+			0, 5, true, 1000 / (3700.0 / 1500) / 50},
+	}
+	for _, tt := range tests {
+		if got := tt.res.RiskOfRuin(); got != tt.ruin {
+			t.Errorf("%s: risk of ruin %g, want %g", tt.name, got, tt.ruin)
+		}
+		median, ok, expected := tt.res.HoursToDouble()
+		if median != tt.median || ok != tt.doubled || math.Abs(expected-tt.expected) > 1e-9 && !math.IsInf(tt.expected, 1) ||
+			math.IsInf(tt.expected, 1) != math.IsInf(expected, 1) {
+			t.Errorf("%s: hours to double %g (%v), at the average win %g, want %g (%v), %g",
+				tt.name, median, ok, expected, tt.median, tt.doubled, tt.expected)
+		}
+	}
+}
+
+func TestKelly(t *testing.T) {
+	var calibration Result
+	set := func(tc, rounds int, edge, variance float64) {
+		i := tc + tableRange
+		calibration.Counts[i] = CountRow{Rounds: rounds, Bet: float64(rounds), Won: edge * float64(rounds)}
+		// unit results summing to the edge with the sample variance
+		d := math.Sqrt(variance * float64(rounds-1) / float64(rounds))
+		calibration.Counts[i].units = edge * float64(rounds)
+		calibration.Counts[i].squares = float64(rounds) * (edge*edge + d*d)
+	}
+	set(-1, 50000, -0.01, 1.3)
+	set(0, 50000, 0, 1.3)
+	set(2, 50000, 0.0143, 1.3)
+	set(3, 500, 0.05, 1.3) // too few rounds
+
+	k := NewKelly(calibration, 0.5)
+	tests := []struct {
+		tc    float64
+		share float64
+	}{
+		{-1, 0},
+		{0, 0},
+		{2.5, 0.5 * 0.0143 / 1.3},
+		{3, 0},
+		{5, 0}, // never sampled
+	}
+	for _, tt := range tests {
+		if got := k.Share(tt.tc); math.Abs(got-tt.share) > 1e-9 {
+			t.Errorf("share at %+g: %g, want %g", tt.tc, got, tt.share)
+		}
+	}
+
+	// the Kelly bettor sits out counts without an edge or with a Kelly bet below
+	// the minimum and rounds down to whole minimums, within the table limits
+	p := testPlayer(t, Table{Decks: 6, Penetration: 0.75, Min: 10, Max: 200}, Bets{Kelly: k}, 1)
+	bets := []struct {
+		bankroll, tc, bet float64
+	}{
+		{10000, -1, 0},
+		{10000, 0, 0},
+		{10000, 2, 50},   // 0.55% of 10000, in whole minimums
+		{1000, 2, 0},     // 0.55% of 1000 is below the minimum
+		{100000, 2, 200}, // table maximum
+		{10000, 3, 0},    // too few calibration rounds
+	}
+	for _, b := range bets {
+		p.Bankroll = b.bankroll
+		if got := p.Bet(b.tc); got != b.bet {
+			t.Errorf("bankroll %g at %+g: bet %g, want %g", b.bankroll, b.tc, got, b.bet)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	ramp, _ := ParseRamp("1:2,2:4")
+	p := testPlayer(t, Table{Decks: 6, Penetration: 0.75, Min: 10, Max: 100}, Bets{Ramp: ramp}, 2)
+	res := Sessions(p, 100, 2000, 50, 100)
+	if len(res.Results) != 50 || len(res.Drawdowns) != 50 {
+		t.Fatalf("%d results, %d drawdowns for 50 sessions", len(res.Results), len(res.Drawdowns))
+	}
+	// the initial bet never exceeds the money left, but doubles and splits of
+	// the last round (up to 4 hands of a 40 bet, doubled) can lose more
+	for i, r := range res.Results {
+		if r < -100-8*40 {
+			t.Errorf("session lost %g from a bankroll of 100", r)
+		}
+		if i > 0 && r < res.Results[i-1] {
+			t.Errorf("results not sorted")
+		}
+	}
+	// a bankroll of ten minimums against a spread of 4 is ruined now and then
+	if res.Ruined == 0 || res.Dealt > 50*2000 {
+		t.Errorf("%d sessions ruined, %d rounds dealt", res.Ruined, res.Dealt)
+	}
+}
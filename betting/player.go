@@ -0,0 +1,140 @@
+package betting
+
+import (
+	"blackjack/counting"
+	"blackjack/game"
+	"blackjack/sim"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Table - the shoe and betting limits
+type Table struct {
+	Decks       int
+	Penetration float64 // share of the shoe dealt before the shuffle
+	Min, Max    float64 // table minimum and maximum bet
+}
+
+// Bets - how a player sizes and places bets
+type Bets struct {
+	Ramp Ramp
+
+	// wonging: after a shuffle watch the table, join once the true count reaches
+	// WongIn and leave when it falls below WongOut (until the next shuffle)
+	Wong    bool
+	WongIn  float64
+	WongOut float64
+
+	// take insurance (half the bet) at and above this true count
+	Insure   bool
+	InsureAt float64
+
+	// size bets by the Kelly criterion on the bankroll instead of the ramp
+	Kelly *Kelly
+}
+
+// Round - one round dealt from the shoe
+type Round struct {
+	Count  float64 // betting true count before the deal
+	Bet    float64 // initial bet, 0 when the player sat it out
+	Result float64 // net win, insurance included
+}
+
+// Player plays rounds from a shoe dealt over many rounds, counting every card
+// seen and betting by the count
+type Player struct {
+	table  Table
+	bets   Bets
+	policy sim.Policy
+	count  *counting.Counter
+	rng    *rand.Rand
+
+	deck   game.Deck
+	cut    int
+	seated bool
+
+	// money the player has: initial bets never exceed it and Kelly bets are
+	// sized on it (0: no bankroll kept)
+	Bankroll float64
+}
+
+// NewPlayer returns a player of policy at table. count (shared with a policy
+// that reads it, e.g. sim.DatasetPolicy) follows the shoe; rng shuffles it.
+func NewPlayer(table Table, bets Bets, policy sim.Policy, count *counting.Counter, rng *rand.Rand) (*Player, error) {
+	switch {
+	case table.Decks < 1:
+		return nil, fmt.Errorf("a counted shoe needs at least one deck")
+	case table.Penetration <= 0 || table.Penetration > 1:
+		return nil, fmt.Errorf("penetration %g is not within (0, 1]", table.Penetration)
+	case table.Min <= 0 || table.Max < table.Min:
+		return nil, fmt.Errorf("table limits %g - %g are invalid", table.Min, table.Max)
+	}
+	return &Player{table: table, bets: bets, policy: policy, count: count, rng: rng}, nil
+}
+
+// Bet returns the bet at true count tc: the ramp in table minimums, or the
+// Kelly bet rounded down to whole minimums, within the limits and the bankroll.
+// A Kelly bettor bets 0 - sits the round out - where the count has no edge or
+// the Kelly bet is less than one minimum.
+func (p *Player) Bet(tc float64) float64 {
+	bet := p.bets.Ramp.Units(tc) * p.table.Min
+	if p.bets.Kelly != nil {
+		bet = math.Floor(p.bets.Kelly.Share(tc)*p.Bankroll/p.table.Min) * p.table.Min
+		if bet < p.table.Min {
+			return 0
+		}
+	}
+	if bet < p.table.Min {
+		bet = p.table.Min
+	}
+	if bet > p.table.Max {
+		bet = p.table.Max
+	}
+	if p.Bankroll > 0 && bet > p.Bankroll {
+		bet = p.Bankroll
+	}
+	return bet
+}
+
+// Next plays the next round, shuffling once the cut card is reached. Rounds the
+// player sits out are still dealt (to the other seats) and counted.
+func (p *Player) Next() Round {
+	if p.deck.Cards == nil || p.deck.Drawn >= p.cut {
+		p.deck = game.NewShoe(p.table.Decks, p.rng)
+		p.cut = int(p.table.Penetration * float64(len(p.deck.Cards)))
+		p.count.Watch(&p.deck)
+		p.seated = !p.bets.Wong
+	}
+
+	tc := p.count.BettingCount()
+	if p.bets.Wong {
+		switch {
+		case !p.seated && tc >= p.bets.WongIn:
+			p.seated = true
+		case p.seated && tc < p.bets.WongOut:
+			p.seated = false
+		}
+	}
+	round := Round{Count: tc}
+	if p.seated {
+		round.Bet = p.Bet(tc)
+	}
+
+	gs := game.StartRound(p.deck)
+	if round.Bet > 0 && p.bets.Insure && gs.DealerShownScore == 1 && p.count.True() >= p.bets.InsureAt {
+		insurance := round.Bet / 2
+		if hole := gs.DealerHand[1].Rank; hole >= 10 {
+			round.Result += 2 * insurance
+		} else {
+			round.Result -= insurance
+		}
+	}
+	sim.PlayRound(&gs, p.policy)
+	p.deck.Drawn = gs.Deck.Drawn
+
+	for _, v := range gs.HandValues {
+		round.Result += round.Bet * float64(v)
+	}
+	return round
+}
@@ -0,0 +1,88 @@
+/*
+Bet sizing for counting players
+
+A Ramp sizes the bet by the true count; a Player plays rounds from a shoe dealt
+over many rounds, betting by the ramp and sitting out counts it does not like
+when wonging. Spread simulates many rounds and reports the player's edge and
+the risk adjusted measures of the game (SCORE, N0, desirability index).
+*/
+package betting
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Step - the bet from a true count on
+type Step struct {
+	Count float64
+	Units float64 // bet in table minimums
+}
+
+// Ramp - bet per true count: the units of the highest step whose count is
+// reached, one unit below the first step
+type Ramp struct {
+	Steps []Step // by ascending count
+}
+
+// ParseRamp reads "count:units" steps, e.g. "1:2,2:4,3:8,4:12" bets 2 units from
+// a true count of +1, 4 from +2 and so on, and 1 unit below +1
+func ParseRamp(spec string) (Ramp, error) {
+	var r Ramp
+	if strings.TrimSpace(spec) == "" {
+		return r, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		count, units, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return Ramp{}, fmt.Errorf("bet ramp step %q: want count:units", part)
+		}
+		c, err := strconv.ParseFloat(count, 64)
+		if err != nil {
+			return Ramp{}, fmt.Errorf("bet ramp step %q: invalid count", part)
+		}
+		u, err := strconv.ParseFloat(units, 64)
+		if err != nil || u <= 0 {
+			return Ramp{}, fmt.Errorf("bet ramp step %q: invalid units", part)
+		}
+		r.Steps = append(r.Steps, Step{Count: c, Units: u})
+	}
+	sort.Slice(r.Steps, func(i, j int) bool { return r.Steps[i].Count < r.Steps[j].Count })
+	return r, nil
+}
+
+// Units returns the bet in table minimums at true count tc
+func (r Ramp) Units(tc float64) float64 {
+	units := 1.0
+	for _, s := range r.Steps {
+		if tc < s.Count {
+			break
+		}
+		units = s.Units
+	}
+	return units
+}
+
+// Spread returns the largest bet of the ramp in units (1 for a flat bet)
+func (r Ramp) Spread() float64 {
+	spread := 1.0
+	for _, s := range r.Steps {
+		if s.Units > spread {
+			spread = s.Units
+		}
+	}
+	return spread
+}
+
+func (r Ramp) String() string {
+	if len(r.Steps) == 0 {
+		return "flat 1 unit"
+	}
+	parts := make([]string, len(r.Steps))
+	for i, s := range r.Steps {
+		parts[i] = fmt.Sprintf("%g from %+g", s.Units, s.Count)
+	}
+	return "1 unit, " + strings.Join(parts, ", ")
+}
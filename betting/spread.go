@@ -0,0 +1,175 @@
+package betting
+
+import (
+	"blackjack/counting"
+	"fmt"
+	"math"
+	"time"
+)
+
+// tableRange - true counts kept one per row by Result (and Kelly), beyond in the edge rows
+const tableRange = 6
+
+// countLabel names the row i of a by-count table
+func countLabel(i int) string {
+	label := fmt.Sprintf("%+d", i-tableRange)
+	switch i {
+	case 0:
+		label = "<=" + label
+	case 2 * tableRange:
+		label = ">=" + label
+	}
+	return label
+}
+
+// CountRow - the rounds played at one true count bucket
+type CountRow struct {
+	Rounds int     // rounds played (with a bet)
+	Bet    float64 // summed initial bets
+	Won    float64 // summed results
+
+	units, squares float64 // summed results per unit bet and their squares
+}
+
+// Edge returns the net win per unit of initial bets at the count
+func (r CountRow) Edge() float64 {
+	if r.Bet == 0 {
+		return 0
+	}
+	return r.Won / r.Bet
+}
+
+// Variance returns the variance of a round's result per unit bet at the count
+func (r CountRow) Variance() float64 {
+	if r.Rounds < 2 {
+		return 0
+	}
+	mean := r.units / float64(r.Rounds)
+	return (r.squares - mean*r.units) / float64(r.Rounds-1)
+}
+
+// Result - outcome of a bet spread over many rounds. Money is in the units of
+// the table limits.
+type Result struct {
+	Rounds int // rounds dealt
+	Played int // rounds with a bet
+
+	Wagered float64 // summed initial bets
+	Won     float64 // net win
+	Mean    float64 // win per round dealt
+	StdDev  float64 // standard deviation of a round's result
+
+	HandsPerHour float64
+	Unit         float64 // table minimum
+
+	Counts  [2*tableRange + 1]CountRow // by true count bucket -tableRange .. +tableRange
+	Elapsed time.Duration
+}
+
+// Spread plays rounds with p and collects the result. handsPerHour is the pace
+// of the rounds dealt: a wonger's rounds sat out take table time too.
+func Spread(p *Player, rounds int, handsPerHour float64) Result {
+	startTime := time.Now()
+	res := Result{HandsPerHour: handsPerHour, Unit: p.table.Min}
+
+	// running mean / variance of a round's result (Welford)
+	var mean, m2 float64
+	for i := 1; i <= rounds; i++ {
+		round := p.Next()
+		delta := round.Result - mean
+		mean += delta / float64(i)
+		m2 += delta * (round.Result - mean)
+
+		if round.Bet > 0 {
+			res.Played++
+			res.Wagered += round.Bet
+			res.Won += round.Result
+			row := &res.Counts[counting.Bucket(round.Count, tableRange)+tableRange]
+			row.Rounds++
+			row.Bet += round.Bet
+			row.Won += round.Result
+			x := round.Result / round.Bet
+			row.units += x
+			row.squares += x * x
+		}
+	}
+	res.Rounds = rounds
+	res.Mean = mean
+	if rounds > 1 {
+		res.StdDev = math.Sqrt(m2 / float64(rounds-1))
+	}
+	res.Elapsed = time.Since(startTime)
+	return res
+}
+
+// Edge returns the net win per unit of initial bets
+func (res Result) Edge() float64 {
+	if res.Wagered == 0 {
+		return 0
+	}
+	return res.Won / res.Wagered
+}
+
+// Hourly returns the expected win and its standard deviation per hour
+func (res Result) Hourly() (win, sd float64) {
+	return res.Mean * res.HandsPerHour, res.StdDev * math.Sqrt(res.HandsPerHour)
+}
+
+// DI returns the desirability index: 1000 times the win per round over its
+// standard deviation (the square root of SCORE)
+func (res Result) DI() float64 {
+	if res.StdDev == 0 {
+		return 0
+	}
+	return 1000 * res.Mean / res.StdDev
+}
+
+// SCORE returns the standardised win per 100 rounds of a $10,000 bankroll bet
+// at full Kelly: 10^6 (win / sd)^2 per round, 0 for a game not worth playing
+func (res Result) SCORE() float64 {
+	if res.Mean <= 0 {
+		return 0
+	}
+	return res.DI() * res.DI()
+}
+
+// N0 returns the rounds after which the expected win equals one standard
+// deviation of the total, +Inf for a game not worth playing
+func (res Result) N0() float64 {
+	if res.Mean <= 0 {
+		return math.Inf(1)
+	}
+	return (res.StdDev / res.Mean) * (res.StdDev / res.Mean)
+}
+
+// Print human format bet spread summary
+func (res Result) Print() {
+	hourly, hourlySD := res.Hourly()
+	played := 0.0
+	if res.Rounds > 0 {
+		played = 100 * float64(res.Played) / float64(res.Rounds)
+	}
+	fmt.Printf("Rounds dealt: %d, played: %d (%.1f%%) (%s)\n", res.Rounds, res.Played,
+		played, res.Elapsed.Round(time.Millisecond))
+	if res.Played > 0 {
+		fmt.Printf("Average bet: %.2f (%.2f units)\n", res.Wagered/float64(res.Played), res.Wagered/float64(res.Played)/res.Unit)
+	}
+	fmt.Printf("Player edge: %+.3f%% of the initial bets\n", 100*res.Edge())
+	fmt.Printf("Win per 100 rounds: %+.2f (%+.3f units), std deviation %.2f\n",
+		100*res.Mean, 100*res.Mean/res.Unit, 10*res.StdDev)
+	fmt.Printf("Hourly at %g rounds/hour: win %+.2f, std deviation %.2f\n", res.HandsPerHour, hourly, hourlySD)
+	if res.Mean > 0 {
+		fmt.Printf("SCORE: %.2f  DI: %.2f  N0: %.0f rounds\n", res.SCORE(), res.DI(), res.N0())
+	} else {
+		fmt.Printf("SCORE: 0  DI: %.2f  N0: never - the game is not worth playing\n", res.DI())
+	}
+
+	fmt.Printf("\n%-6s %8s %9s %9s\n", "tc", "rounds", "bet", "edge")
+	for i, row := range res.Counts {
+		if row.Rounds == 0 {
+			continue
+		}
+		fmt.Printf("%-6s %7.2f%% %9.2f %+8.3f%%\n", countLabel(i), 100*float64(row.Rounds)/float64(res.Played),
+			row.Bet/float64(row.Rounds), 100*row.Edge())
+	}
+}
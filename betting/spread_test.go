@@ -0,0 +1,159 @@
+package betting
+
+import (
+	"blackjack/counting"
+	"blackjack/game"
+	"blackjack/strategy"
+	"math"
+	"testing"
+)
+
+func TestParseRamp(t *testing.T) {
+	tests := []struct {
+		spec   string
+		units  map[float64]float64 // bet in units by true count
+		spread float64
+		err    bool
+	}{
+		{spec: "", units: map[float64]float64{-5: 1, 0: 1, 10: 1}, spread: 1},
+		{spec: "1:2,2:4,3:8,4:12", units: map[float64]float64{-1: 1, 0.99: 1, 1: 2, 2.5: 4, 4: 12, 9: 12}, spread: 12},
+		{spec: " 3:8 , 1:2 ", units: map[float64]float64{0: 1, 1: 2, 2: 2, 3: 8}, spread: 8},
+		{spec: "-1:0.5,2:3", units: map[float64]float64{-2: 1, -1: 0.5, 1: 0.5, 2: 3}, spread: 3},
+		{spec: "1", err: true},
+		{spec: "x:2", err: true},
+		{spec: "1:0", err: true},
+		{spec: "1:-2", err: true},
+	}
+	for _, tt := range tests {
+		r, err := ParseRamp(tt.spec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseRamp(%q): no error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRamp(%q): %v", tt.spec, err)
+			continue
+		}
+		for tc, want := range tt.units {
+			if got := r.Units(tc); got != want {
+				t.Errorf("ParseRamp(%q).Units(%g) = %g, want %g", tt.spec, tc, got, want)
+			}
+		}
+		if got := r.Spread(); got != tt.spread {
+			t.Errorf("ParseRamp(%q).Spread() = %g, want %g", tt.spec, got, tt.spread)
+		}
+	}
+}
+
+func TestResultMeasures(t *testing.T) {
+	tests := []struct {
+		name             string
+		mean, sd         float64
+		di, score, n0    float64
+		hourly, hourlySD float64
+	}{
+		// 1% of a unit per round at a standard deviation of 1.1 units
+		{"winning", 0.01, 1.1, 1000 * 0.01 / 1.1, (1000 * 0.01 / 1.1) * (1000 * 0.01 / 1.1), (1.1 / 0.01) * (1.1 / 0.01), 1, 11},
+		{"losing", -0.005, 1.15, 1000 * -0.005 / 1.15, 0, math.Inf(1), -0.5, 11.5},
+		{"no spread", 0, 0, 0, 0, math.Inf(1), 0, 0},
+	}
+	for _, tt := range tests {
+		res := Result{Mean: tt.mean, StdDev: tt.sd, HandsPerHour: 100}
+		close := func(got, want float64) bool {
+			return got == want || math.Abs(got-want) < 1e-9*math.Max(1, math.Abs(want))
+		}
+		if got := res.DI(); !close(got, tt.di) {
+			t.Errorf("%s: DI %g, want %g", tt.name, got, tt.di)
+		}
+		if got := res.SCORE(); !close(got, tt.score) {
+			t.Errorf("%s: SCORE %g, want %g", tt.name, got, tt.score)
+		}
+		if got := res.N0(); !close(got, tt.n0) {
+			t.Errorf("%s: N0 %g, want %g", tt.name, got, tt.n0)
+		}
+		if win, sd := res.Hourly(); !close(win, tt.hourly) || !close(sd, tt.hourlySD) {
+			t.Errorf("%s: hourly %g ± %g, want %g ± %g", tt.name, win, sd, tt.hourly, tt.hourlySD)
+		}
+	}
+}
+
+func TestCountRow(t *testing.T) {
+	var row CountRow
+	for _, r := range []struct{ bet, result float64 }{{10, 10}, {10, -10}, {20, 30}, {20, -20}} {
+		row.Rounds++
+		row.Bet += r.bet
+		row.Won += r.result
+		row.units += r.result / r.bet
+		row.squares += (r.result / r.bet) * (r.result / r.bet)
+	}
+	// results per unit 1, -1, 1.5, -1: mean 0.125, sample variance 1.729167
+	if got := row.Edge(); got != 10.0/60 {
+		t.Errorf("edge %g, want %g", got, 10.0/60)
+	}
+	if got, want := row.Variance(), (1+1+2.25+1-4*0.125*0.125)/3; math.Abs(got-want) > 1e-12 {
+		t.Errorf("variance %g, want %g", got, want)
+	}
+	if got := (CountRow{}).Edge(); got != 0 {
+		t.Errorf("empty row: edge %g", got)
+	}
+}
+
+func testPlayer(t *testing.T, table Table, bets Bets, seed int64) *Player {
+	t.Helper()
+	count := counting.NewCounter(counting.HiLo, table.Decks*52)
+	rng, _ := game.NewRand(seed)
+	p, err := NewPlayer(table, bets, strategy.Textbook(), &count, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSpread(t *testing.T) {
+	ramp, _ := ParseRamp("1:2,2:4,3:8")
+	table := Table{Decks: 6, Penetration: 0.75, Min: 10, Max: 80}
+
+	res := Spread(testPlayer(t, table, Bets{Ramp: ramp}, 1), 20000, 100)
+	if res.Rounds != 20000 || res.Played != 20000 {
+		t.Errorf("flat seat: %d rounds dealt, %d played, want 20000 each", res.Rounds, res.Played)
+	}
+	if avg := res.Wagered / float64(res.Played); avg < table.Min || avg > table.Max {
+		t.Errorf("average bet %g outside the table limits", avg)
+	}
+	rounds := 0
+	for _, row := range res.Counts {
+		rounds += row.Rounds
+	}
+	if rounds != res.Played {
+		t.Errorf("rounds by count add up to %d, want %d", rounds, res.Played)
+	}
+
+	// a wonger sits out the counts below the entry
+	wong := Bets{Ramp: ramp, Wong: true, WongIn: 1, WongOut: 0}
+	res = Spread(testPlayer(t, table, wong, 1), 20000, 100)
+	if res.Played == 0 || res.Played >= res.Rounds {
+		t.Errorf("wonger played %d of %d rounds", res.Played, res.Rounds)
+	}
+	for i, row := range res.Counts[:tableRange] {
+		if row.Rounds != 0 {
+			t.Errorf("wonger played %d rounds at %s, below the exit", row.Rounds, countLabel(i))
+		}
+	}
+}
+
+func TestNewPlayerLimits(t *testing.T) {
+	for _, table := range []Table{
+		{Decks: 0, Penetration: 0.75, Min: 10, Max: 100},
+		{Decks: 6, Penetration: 0, Min: 10, Max: 100},
+		{Decks: 6, Penetration: 1.1, Min: 10, Max: 100},
+		{Decks: 6, Penetration: 0.75, Min: 0, Max: 100},
+		{Decks: 6, Penetration: 0.75, Min: 10, Max: 5},
+	} {
+		count := counting.NewCounter(counting.HiLo, 52)
+		if _, err := NewPlayer(table, Bets{}, strategy.Textbook(), &count, nil); err == nil {
+			t.Errorf("%+v: no error", table)
+		}
+	}
+}
@@ -0,0 +1,1013 @@
+package main
+
+import (
+	"blackjack/betting"
+	"blackjack/counting"
+	"blackjack/game"
+	"blackjack/heatmap"
+	"blackjack/report"
+	"blackjack/sim"
+	"blackjack/solver"
+	"blackjack/strategy"
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runCommand dispatches a sub command given on the command line
+func runCommand(name string, args []string) error {
+	switch name {
+	case "solve":
+		return cmdSolve(args)
+	case "infinite":
+		return cmdInfinite(args)
+	case "evaluate":
+		return cmdEvaluate(args)
+	case "convert":
+		return cmdConvert(args)
+	case "export":
+		return cmdExport(args)
+	case "merge":
+		return cmdMerge(args)
+	case "strategy":
+		return cmdStrategy(args)
+	case "heatmap":
+		return cmdHeatmap(args)
+	case "report":
+		return cmdReport(args)
+	case "advise":
+		return cmdAdvise(args)
+	case "diff":
+		return cmdDiff(args)
+	case "indices":
+		return cmdIndices(args)
+	case "play":
+		return cmdPlay(args)
+	case "spread":
+		return cmdSpread(args)
+	case "bankroll":
+		return cmdBankroll(args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// ruleFlags registers the table rule flags on fs, defaulting to the engine rules
+func ruleFlags(fs *flag.FlagSet) *game.Rules {
+	rules := game.DefaultRules()
+	fs.IntVar(&rules.Decks, "decks", rules.Decks, "number of decks in the shoe")
+	fs.BoolVar(&rules.DealerHitsSoft17, "h17", rules.DealerHitsSoft17, "dealer hits soft 17")
+	fs.BoolVar(&rules.DoubleAfterSplit, "das", rules.DoubleAfterSplit, "double after split allowed")
+	fs.BoolVar(&rules.HitSplitAces, "hsa", rules.HitSplitAces, "split aces may be hit")
+	fs.BoolVar(&rules.Surrender, "surrender", rules.Surrender, "late surrender allowed")
+	fs.BoolVar(&rules.DealerPeek, "peek", rules.DealerPeek, "dealer peeks for blackjack")
+	fs.Float64Var(&rules.BlackjackPays, "bj", rules.BlackjackPays, "blackjack payout (1.5 = 3:2)")
+	return &rules
+}
+
+// solve - exact composition dependent EVs for every hand vs upcard
+func cmdSolve(args []string) error {
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	rules := ruleFlags(fs)
+	out := fs.String("out", "bj_exact_data.json", "output dataset file (.bjsd for binary, .gz to compress)")
+	fs.Parse(args)
+
+	startTime := time.Now()
+	fmt.Printf("Solving exact EVs (%s)...\n", rules)
+
+	dataset, err := solver.SolveExact(*rules)
+	if err != nil {
+		return err
+	}
+	if err := dataset.WriteFile(*out); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exact EVs written to %s (%s)\n", *out, time.Since(startTime).Round(time.Millisecond))
+	return nil
+}
+
+// infinite - infinite deck basic strategy and house edge
+func cmdInfinite(args []string) error {
+	fs := flag.NewFlagSet("infinite", flag.ExitOnError)
+	rules := ruleFlags(fs)
+	out := fs.String("out", "bj_infinite_data.json", "output dataset file (empty to skip)")
+	fs.Parse(args)
+
+	startTime := time.Now()
+	res := solver.SolveInfinite(*rules)
+	res.Print()
+
+	if *out != "" {
+		if err := res.ToSimData().WriteFile(*out); err != nil {
+			return err
+		}
+		fmt.Printf("Infinite deck EVs written to %s\n", *out)
+	}
+	fmt.Printf("Solved in %s\n", time.Since(startTime).Round(time.Millisecond))
+	return nil
+}
+
+// evaluate - play a fixed strategy straight through and measure its return
+func cmdEvaluate(args []string) error {
+	fs := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	rounds := fs.Int("rounds", 10_000_000, "number of rounds to play")
+	policyName := fs.String("policy", "dataset", `strategy to play: "dataset", "textbook", a reference chart name or a chart file`)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset for the dataset policy")
+	rules := ruleFlags(fs)
+	infinite := fs.Bool("infinite", false, "deal from an infinite deck")
+	seed := fs.Int64("seed", 0, "random seed (0 for a time based seed)")
+	fs.Parse(args)
+	isSet := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { isSet[f.Name] = true })
+
+	// the table defaults to the dataset's own game for a dataset policy (the
+	// engine's single deck for a legacy dataset), to 6 decks for a chart
+	var policy sim.Policy
+	switch *policyName {
+	case "dataset":
+		dataset, err := sim.LoadDataset(*dataFile)
+		if err != nil {
+			return err
+		}
+		meta := dataset.Metadata()
+		fmt.Printf("Dataset %s: %s\n", *dataFile, meta)
+		if meta.Known() && !isSet["decks"] {
+			rules.Decks = meta.Rules.Decks
+		}
+		if meta.Known() && !isSet["infinite"] {
+			*infinite = meta.InfiniteDeck
+		}
+		policy = sim.DatasetPolicy{Data: dataset.Pooled()} // rounds are dealt from fresh shoes
+	default:
+		chart, err := loadChart(*policyName)
+		if err != nil {
+			return err
+		}
+		policy = chart
+		if !isSet["decks"] {
+			rules.Decks = 6
+		}
+	}
+
+	// the engine plays its own rules - only the shoe can change
+	engine := game.DefaultRules()
+	engine.Decks = rules.Decks
+	if *rules != engine {
+		return fmt.Errorf("the game engine plays %s only, not %s", engine, rules)
+	}
+	if rules.Decks < 1 {
+		return fmt.Errorf("-decks must be at least 1")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng, _ := game.NewRand(*seed)
+
+	table := rules.String()
+	if *infinite {
+		table = "infinite deck"
+	}
+	fmt.Printf("Evaluating %s strategy over %d rounds (%s, seed %d)...\n", *policyName, *rounds, table, *seed)
+	res := sim.EvaluateStrategy(*rounds, policy, rules.Decks, *infinite, rng)
+	res.Print()
+	return nil
+}
+
+// convert - rewrite a dataset in another format, e.g. JSON to compressed binary
+func cmdConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: convert <in> <out>")
+		fmt.Fprintln(fs.Output(), "the input format is detected, the output format follows the name: .json, .bjsd (binary), + .gz to compress")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("convert needs an input and an output file")
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	dataset, inFormat, err := sim.DecodeDataset(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if err := dataset.WriteFile(out); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(out)
+	if err != nil {
+		return err
+	}
+	outFormat, compressed := sim.FormatFor(out)
+	if compressed {
+		fmt.Printf("Converted %s (%s, %d bytes) to %s (%s gzip, %d bytes)\n", in, inFormat, len(data), out, outFormat, info.Size())
+	} else {
+		fmt.Printf("Converted %s (%s, %d bytes) to %s (%s, %d bytes)\n", in, inFormat, len(data), out, outFormat, info.Size())
+	}
+	return nil
+}
+
+// export - tidy table (one row per dealer up, player total, category, action) as CSV or TSV
+func cmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset to export")
+	out := fs.String("out", "", "output file (.csv or .tsv, empty for stdout)")
+	format := fs.String("format", "", `"csv" or "tsv" (default: from the -out extension, else csv)`)
+	fs.Parse(args)
+
+	if *format == "" {
+		*format = "csv"
+		if strings.HasSuffix(strings.ToLower(*out), ".tsv") {
+			*format = "tsv"
+		}
+	}
+	var comma rune
+	switch *format {
+	case "csv":
+		comma = ','
+	case "tsv":
+		comma = '\t'
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	dataset, err := sim.LoadDataset(*dataFile)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return dataset.WriteTable(os.Stdout, comma)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := dataset.WriteTable(file, comma); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %s to %s\n", *dataFile, *out)
+	return nil
+}
+
+// merge - combine datasets from independent runs (e.g. several machines) into one
+func cmdMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("out", "bj_sim_data_merged.json", "merged dataset file (.bjsd for binary, .gz to compress)")
+	force := fs.Bool("force", false, "merge datasets with different layouts or rules (union of their records)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: merge [-out file] [-force] <dataset> <dataset> ...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return fmt.Errorf("merge needs at least two datasets")
+	}
+
+	// the same dataset twice would count its hands twice
+	for i, filename := range fs.Args() {
+		for _, other := range fs.Args()[:i] {
+			if sameFile(filename, other) {
+				return fmt.Errorf("%s is given twice (as %s): merging a dataset with itself double counts its hands", filename, other)
+			}
+		}
+	}
+
+	var merged sim.SimDataMap
+	runs := map[[2]int64]string{} // file of each seed and hand count
+	for i, filename := range fs.Args() {
+		dataset, err := sim.LoadDataset(filename)
+		if err != nil {
+			return err
+		}
+		meta := dataset.Metadata()
+		fmt.Printf("%s: %d trials, %s\n", filename, dataset.TotalTrials(), meta)
+
+		// copies of one run share its seed and hands - likely the same hands
+		if meta.Seed != 0 {
+			run := [2]int64{meta.Seed, int64(meta.Hands)}
+			if other, ok := runs[run]; ok {
+				if !*force {
+					return fmt.Errorf("%s and %s have the same seed %d and %d hands - copies of one run would be double counted (use -force to merge anyway)",
+						other, filename, meta.Seed, meta.Hands)
+				}
+				fmt.Printf("WARNING: %s and %s have the same seed %d and %d hands - if they are copies of one run, its hands are counted twice\n",
+					other, filename, meta.Seed, meta.Hands)
+			}
+			runs[run] = filename
+		}
+
+		if i == 0 {
+			merged = dataset
+			continue
+		}
+		if !meta.Known() || !merged.Metadata().Known() {
+			fmt.Printf("Warning: cannot check the rules of %s against %s (no metadata)\n", filename, fs.Arg(0))
+		} else if err := merged.Metadata().SameRules(*meta); err != nil {
+			if !*force {
+				return fmt.Errorf("%s was produced under different rules from %s: %v (use -force to merge anyway)", filename, fs.Arg(0), err)
+			}
+			fmt.Printf("Warning: %s was produced under different rules from %s: %v\n", filename, fs.Arg(0), err)
+		}
+		if !merged.SameLayout(dataset) {
+			if !*force {
+				return fmt.Errorf("%s has a different layout from %s (use -force to merge anyway)", filename, fs.Arg(0))
+			}
+			fmt.Printf("Warning: %s has a different layout from %s, merging the union of their records\n", filename, fs.Arg(0))
+		}
+		merged.Merge(dataset)
+	}
+
+	if err := merged.WriteFile(*out); err != nil {
+		return err
+	}
+	fmt.Printf("Merged %d datasets (%d trials) into %s\n", fs.NArg(), merged.TotalTrials(), *out)
+	return nil
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	sa, errA := os.Stat(a)
+	sb, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(sa, sb)
+}
+
+// strategy - basic strategy charts from a dataset (or a chart file) as ANSI colour, Markdown or text
+func cmdStrategy(args []string) error {
+	fs := flag.NewFlagSet("strategy", flag.ExitOnError)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset to read the best actions from")
+	chartName := fs.String("chart", "", `render "textbook", a reference chart name or a chart file instead of a dataset`)
+	formatName := fs.String("format", "", `"ansi", "markdown" or "text" (default: ansi on a terminal, else text)`)
+	tiny := fs.Float64("margin", 0, "mark entries whose best action leads the next best by less than this EV")
+	alpha := fs.Float64("alpha", sim.DefaultAlpha, "mark entries whose best action is not significant at this level (0 to disable)")
+	split := fs.Bool("split", false, "chart of post-split hands")
+	tc := fs.String("tc", "", "chart at this true count bucket of a counted dataset (default: every count pooled)")
+	out := fs.String("out", "", "output file (empty for stdout)")
+	fs.Parse(args)
+
+	if *formatName == "" {
+		*formatName = "text"
+		if *out == "" && isTerminal(os.Stdout) {
+			*formatName = "ansi"
+		}
+	}
+	format, err := strategy.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var chart strategy.Chart
+	var margins *strategy.Margins
+	switch *chartName {
+	case "":
+		dataset, err := sim.LoadDataset(*dataFile)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s (%s)", *dataFile, dataset.Metadata())
+		dataset, count, err := countView(dataset, *tc)
+		if err != nil {
+			return err
+		}
+		if *tc != "" {
+			name = fmt.Sprintf("true count %+d, %s", count, name)
+		}
+		if *split {
+			name = "post-split hands, " + name
+		}
+		var m strategy.Margins
+		chart, m = strategy.FromDataset(dataset, sim.Context{Split: *split, Count: count}, name)
+		margins = &m
+	default:
+		if chart, err = loadChart(*chartName); err != nil {
+			return err
+		}
+	}
+
+	if *out == "" {
+		return chart.Render(os.Stdout, format, margins, *tiny, *alpha)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := chart.Render(file, format, margins, *tiny, *alpha); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadChart returns the textbook chart, an embedded reference chart or a chart file
+func loadChart(name string) (strategy.Chart, error) {
+	if name == "textbook" {
+		return strategy.Textbook(), nil
+	}
+	for _, ref := range strategy.ReferenceNames() {
+		if name == ref {
+			return strategy.Reference(name)
+		}
+	}
+	return strategy.LoadChart(name)
+}
+
+// countView resolves a -tc flag: a counted dataset is pooled over every true
+// count unless tc names a bucket, which the returned count then selects
+func countView(dataset sim.SimDataMap, tc string) (sim.SimDataMap, int, error) {
+	if tc == "" {
+		return dataset.Pooled(), 0, nil
+	}
+	count, err := strconv.Atoi(tc)
+	if err != nil {
+		return sim.SimDataMap{}, 0, fmt.Errorf("invalid true count %q", tc)
+	}
+	r := dataset.CountRange()
+	switch {
+	case r == 0:
+		return sim.SimDataMap{}, 0, fmt.Errorf("the dataset is not counted (simulate with -count)")
+	case count < -r || count > r:
+		return sim.SimDataMap{}, 0, fmt.Errorf("true count %+d is outside of the dataset's buckets ±%d", count, r)
+	}
+	return dataset, count, nil
+}
+
+// isTerminal reports whether f is a character device (an interactive terminal)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// heatmap - strategy and EV heatmaps per hand category as PNG and SVG
+func cmdHeatmap(args []string) error {
+	fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset to draw")
+	dir := fs.String("dir", "analysis", "output directory")
+	formats := fs.String("format", "png,svg", "comma separated image formats: png, svg")
+	fs.Parse(args)
+
+	dataset, err := sim.LoadDataset(*dataFile)
+	if err != nil {
+		return err
+	}
+	dataset = dataset.Pooled() // every true count of a counted dataset
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	for _, ext := range strings.Split(*formats, ",") {
+		ext = strings.TrimSpace(ext)
+		if ext != "png" && ext != "svg" {
+			return fmt.Errorf("unknown image format %q", ext)
+		}
+		for category := 0; category < sim.CategorySlots; category++ {
+			grid := heatmap.FromDataset(dataset, category)
+			for _, kind := range []heatmap.Kind{heatmap.KindStrategy, heatmap.KindEV} {
+				filename := filepath.Join(*dir, grid.FileName(kind, ext))
+				if err := writeHeatmap(filename, grid, kind, ext); err != nil {
+					return err
+				}
+				fmt.Println("Wrote", filename)
+			}
+		}
+	}
+	return nil
+}
+
+func writeHeatmap(filename string, grid heatmap.Grid, kind heatmap.Kind, ext string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if ext == "svg" {
+		err = grid.WriteSVG(file, kind)
+	} else {
+		err = grid.WritePNG(file, kind)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// report - self-contained HTML summary of a dataset
+func cmdReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset to report on")
+	out := fs.String("out", "bj_report.html", "HTML file to write")
+	title := fs.String("title", "Blackjack simulation report", "report title")
+	top := fs.Int("top", 10, "number of best / worst situations listed")
+	fs.Parse(args)
+	if *top < 0 {
+		return fmt.Errorf("-top %d: the number of situations listed cannot be negative", *top)
+	}
+
+	dataset, err := sim.LoadDataset(*dataFile)
+	if err != nil {
+		return err
+	}
+	dataset = dataset.Pooled() // the report covers play over every count
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	opts := report.Options{Title: *title, DataFile: *dataFile, Top: *top}
+	if err := report.Write(file, dataset, opts); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Report written to %s\n", *out)
+	return nil
+}
+
+// advise - the dataset's EVs and best action for one hand against a dealer upcard
+func cmdAdvise(args []string) error {
+	fs := flag.NewFlagSet("advise", flag.ExitOnError)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset to read the EVs from")
+	upText := fs.String("up", "", "dealer upcard: A, 2 .. 9, T")
+	handText := fs.String("hand", "", `player cards e.g. "T,6", "A,7" or "8,8"`)
+	alpha := fs.Float64("alpha", sim.DefaultAlpha, "significance level for the best action")
+	split := fs.Bool("split", false, "the hand came from a split")
+	tc := fs.String("tc", "", "true count bucket of a counted dataset (default: every count pooled)")
+	fs.Parse(args)
+
+	up, err := parseCard(*upText)
+	if err != nil {
+		return fmt.Errorf("-up: %w", err)
+	}
+	hand, err := parseHand(*handText)
+	if err != nil {
+		return fmt.Errorf("-hand: %w", err)
+	}
+	ctx, player, category := sim.StateOf(hand)
+	ctx.Split = *split
+
+	dataset, err := sim.LoadDataset(*dataFile)
+	if err != nil {
+		return err
+	}
+	dataset, ctx.Count, err = countView(dataset, *tc)
+	if err != nil {
+		return err
+	}
+	cell := dataset.Cell(ctx, up, player, category)
+	if cell == nil || !cell.Reached() {
+		return fmt.Errorf("%s has no trials for %s against %s (%s decision)", *dataFile, *handText, sim.UpLabel(up), ctx)
+	}
+
+	sig := cell.Significance()
+	fmt.Printf("%s %d (%s) against dealer %s, %s decision (%s)\n", sim.CategoryNames[category], sim.KeyTotal(player, category),
+		*handText, sim.UpLabel(up), ctx, *dataFile)
+	fmt.Printf("  %-9s %9s %9s %11s\n", "action", "EV", "std err", "trials")
+	for action, sd := range cell.Data {
+		if !cell.Has(action) || sd.Trials == 0 {
+			continue
+		}
+		se := "-"
+		if v, ok := sd.StdErr(); ok {
+			se = fmt.Sprintf("%.5f", v)
+		}
+		mark := ""
+		if action == sig.Best {
+			mark = "  best"
+		}
+		fmt.Printf("  %-9s %+9.5f %9s %11d%s\n", sim.ActionNames[action], sd.ExpectedValue, se, sd.Trials, mark)
+	}
+
+	best := sim.ActionNames[sig.Best]
+	switch {
+	case sig.RunnerUp < 0:
+		fmt.Printf("%s is the only action with trials\n", best)
+	case !sig.Known:
+		fmt.Printf("%s leads %s by %.5f - too few trials to test (unresolved)\n", best, sim.ActionNames[sig.RunnerUp], sig.Margin)
+	default:
+		method := "independent errors"
+		if sig.Paired {
+			method = "paired on the same deals"
+		}
+		verdict := "resolved"
+		if !sig.Resolved(*alpha) {
+			verdict = "UNRESOLVED - more hands needed"
+		}
+		fmt.Printf("%s leads %s by %.5f +/- %.5f, p = %.3g (%s): %s\n",
+			best, sim.ActionNames[sig.RunnerUp], sig.Margin, sig.StdErr, sig.PValue, method, verdict)
+	}
+	return nil
+}
+
+// parseCard reads a card value: A (1), 2 .. 9, T / J / Q / K / 10 (10)
+func parseCard(s string) (int, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "A":
+		return 1, nil
+	case "T", "J", "Q", "K", "10":
+		return 10, nil
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || v < 2 || v > 9 {
+		return 0, fmt.Errorf("invalid card %q", s)
+	}
+	return v, nil
+}
+
+// parseHand reads comma separated cards (suits are not needed by the keys)
+func parseHand(s string) ([]game.Card, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("need at least two cards, got %q", s)
+	}
+	hand := make([]game.Card, len(parts))
+	raw := 0 // aces as 1 - the engine ends the hand at 21
+	for i, part := range parts {
+		v, err := parseCard(part)
+		if err != nil {
+			return nil, err
+		}
+		hand[i] = game.Card{Rank: v}
+		raw += v
+	}
+	if raw >= 21 {
+		return nil, fmt.Errorf("%q has no decision left", s)
+	}
+	return hand, nil
+}
+
+// diff - disagreements between a dataset's best actions and a reference chart
+func cmdDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset to check")
+	refName := fs.String("reference", "", `reference chart name or chart file (default: the chart matching the dataset rules)`)
+	alpha := fs.Float64("alpha", sim.DefaultAlpha, "significance level for a disagreement")
+	split := fs.Bool("split", false, "check post-split hands (default reference: the dataset's own original hands)")
+	list := fs.Bool("list", false, "list the reference charts and exit")
+	fs.Parse(args)
+
+	if *list {
+		for _, name := range strategy.ReferenceNames() {
+			chart, _ := strategy.Reference(name)
+			fmt.Printf("%-14s %s\n", name, chart.Name)
+		}
+		return nil
+	}
+
+	dataset, err := sim.LoadDataset(*dataFile)
+	if err != nil {
+		return err
+	}
+	meta := dataset.Metadata()
+	dataset = dataset.Pooled()
+	rules := meta.Rules
+	if !meta.Known() {
+		rules = game.DefaultRules()
+		fmt.Fprintf(os.Stderr, "Warning: %s has no metadata, assuming the engine rules\n", *dataFile)
+	}
+	var ref strategy.Chart
+	own := *split && *refName == "" // the dataset against itself
+	switch {
+	case own:
+		// where post-split play deviates from play on original hands
+		*refName = "original hands"
+		ref, _ = strategy.FromDataset(dataset, sim.Context{}, *dataFile)
+	case *refName == "":
+		*refName = strategy.ReferenceName(rules)
+		if meta.InfiniteDeck {
+			*refName = strategy.ReferenceName(game.Rules{Decks: 0, DealerHitsSoft17: rules.DealerHitsSoft17, DoubleAfterSplit: rules.DoubleAfterSplit})
+		}
+		fallthrough
+	default:
+		if ref, err = loadChart(*refName); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Dataset:   %s (%s)\n", *dataFile, meta)
+	if *split {
+		fmt.Println("Hands:     post-split")
+	}
+	fmt.Printf("Reference: %s (%s)\n", *refName, ref.Name)
+	if !own && (!rules.DealerPeek || rules.Surrender || rules.Decks == 3 || rules.Decks == 4 || rules.Decks == 5 || rules.Decks > 8) {
+		fmt.Println("Note: the reference charts assume 1, 2, 6 or 8 decks, a dealer peek and no surrender;")
+		fmt.Println("      other rules move some decisions, mostly against T and A.")
+	}
+
+	diffs, compared := strategy.Diff(dataset, sim.Context{Split: *split}, ref)
+	significant := 0
+	if len(diffs) > 0 {
+		fmt.Printf("\n%-5s %-2s  %-7s %9s  %-9s %9s %9s %11s %9s\n",
+			"hand", "up", "dataset", "EV", "reference", "EV", "gap", "trials", "p")
+		for _, d := range diffs {
+			p, verdict := "-", "unknown"
+			if d.Significance.Known {
+				p = fmt.Sprintf("%.3g", d.Significance.PValue)
+				verdict = "noise"
+				if d.Significance.Resolved(*alpha) {
+					verdict = "SIGNIFICANT"
+					significant++
+				}
+			}
+			fmt.Printf("%-5s %-2s  %-7s %+9.5f  %-9s %+9.5f %9.5f %11d %9s  %s\n",
+				d.Hand, sim.UpLabel(d.Up), sim.ActionNames[d.Dataset], d.DatasetEV,
+				sim.ActionNames[d.Reference], d.ReferenceEV, d.DatasetEV-d.ReferenceEV, d.Trials, p, verdict)
+		}
+	}
+	fmt.Printf("\n%d of %d reached chart entries disagree with the reference, %d significantly (p < %g)\n",
+		len(diffs), compared, significant, *alpha)
+	return nil
+}
+
+// indices - true counts at which count deviations pay, fitted from a counted dataset
+func cmdIndices(args []string) error {
+	fs := flag.NewFlagSet("indices", flag.ExitOnError)
+	dataFile := fs.String("data", "bj_sim_data.json", "counted dataset (simulate with -count)")
+	all := fs.Bool("all", false, "search every chart entry for deviations instead of the Illustrious 18 and Fab 4")
+	fs.Parse(args)
+
+	dataset, err := sim.LoadDataset(*dataFile)
+	if err != nil {
+		return err
+	}
+	meta := dataset.Metadata()
+	r := dataset.CountRange()
+	if r == 0 {
+		return fmt.Errorf("%s is not counted (simulate with -count)", *dataFile)
+	}
+	fmt.Printf("Dataset: %s (%s)\n", *dataFile, meta)
+	fmt.Printf("Index: the true count from which the play beats the basic play, fitted over buckets %+d .. %+d\n", -r+1, r-1)
+	if meta.Count != counting.HiLo.Name {
+		fmt.Printf("Note: the published indices are Hi-Lo indices, %s indices differ\n", meta.Count)
+	}
+
+	print := func(title string, indices []strategy.Index) {
+		fmt.Printf("\n%s\n%-22s %-9s %9s %6s %9s %9s %7s\n", title,
+			"play", "else", "published", "index", "crossing", "slope", "buckets")
+		for _, idx := range indices {
+			published := "-"
+			if idx.Listed {
+				published = fmt.Sprintf("%+d", idx.Published)
+			}
+			basic := sim.ActionNames[idx.Basic]
+			if idx.Insurance {
+				basic = "decline"
+			}
+			if !idx.Known {
+				fmt.Printf("%-22s %-9s %9s %6s  %s\n", idx.Play, basic, published, "-", idx.Reason)
+				continue
+			}
+			fmt.Printf("%-22s %-9s %9s %+6d %+9.2f %9.5f %7d\n", idx.Play, basic, published,
+				idx.Index, idx.Crossing, idx.Slope, idx.Buckets)
+		}
+	}
+	if *all {
+		print("Deviations within the recorded counts", strategy.Deviations(dataset))
+		return nil
+	}
+	print("Illustrious 18", strategy.Indices(dataset, strategy.Illustrious18))
+	// surrender EVs are only recorded under rules with surrender (the simulation
+	// engine has none)
+	if meta.Rules.Surrender {
+		print("Fab 4 (surrender)", strategy.Indices(dataset, strategy.Fab4))
+	} else {
+		fmt.Printf("\nFab 4 (surrender) skipped: the dataset's rules (%s) have no surrender\n", meta.Rules)
+	}
+	return nil
+}
+
+// play - interactive rounds dealt from a shoe over many rounds, showing the count
+func cmdPlay(args []string) error {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	decks := fs.Int("decks", 6, "number of decks in the shoe")
+	system := fs.String("count", counting.HiLo.Name, `counting system shown ("" for none): `+strings.Join(counting.Names(), ", ")+" or ten tags for A,2..9,T")
+	penetration := fs.Float64("penetration", 0.75, "share of the shoe dealt before the shuffle")
+	fs.Parse(args)
+
+	if *penetration <= 0 || *penetration > 1 {
+		return fmt.Errorf("penetration %g is not within (0, 1]", *penetration)
+	}
+	var counter *counting.Counter
+	if *system != "" {
+		s, err := counting.Lookup(*system)
+		if err != nil {
+			return err
+		}
+		c := counting.NewCounter(s, 0)
+		counter = &c
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var deck game.Deck
+	cut := 0
+	for {
+		if deck.Cards == nil || deck.Drawn >= cut {
+			deck = game.NewShoe(*decks, nil)
+			cut = int(*penetration * float64(len(deck.Cards)))
+			if counter != nil {
+				counter.Watch(&deck)
+			}
+			fmt.Printf("\nShuffled %d decks\n", *decks)
+		}
+		blackjackCLI(&deck, counter)
+
+		fmt.Print("Press Enter for the next round, q to quit: ")
+		input, err := reader.ReadString('\n')
+		if err != nil || strings.TrimSpace(input) == "q" {
+			return nil
+		}
+	}
+}
+
+// spread - a counter's bet spread over shoes dealt over many rounds: edge, hourly win, SCORE
+func cmdSpread(args []string) error {
+	fs := flag.NewFlagSet("spread", flag.ExitOnError)
+	decks := fs.Int("decks", 6, "number of decks in the shoe")
+	penetration := fs.Float64("penetration", 0.75, "share of the shoe dealt before the shuffle")
+	system := fs.String("count", counting.HiLo.Name, strings.Join(counting.Names(), ", ")+" or ten tags for A,2..9,T")
+	rampSpec := fs.String("ramp", "1:2,2:4,3:8,4:12", `bet ramp "count:units,..." in table minimums (1 unit below the first step)`)
+	min := fs.Float64("min", 10, "table minimum bet (one unit)")
+	max := fs.Float64("max", 500, "table maximum bet")
+	wong := fs.Bool("wong", false, "wong: join at -wong-in, leave below -wong-out until the shuffle")
+	wongIn := fs.Float64("wong-in", 1, "true count to join the table at when wonging")
+	wongOut := fs.Float64("wong-out", -1, "true count to leave the table below when wonging")
+	insure := fs.Bool("insure", false, "take insurance at and above -insure-at")
+	insureAt := fs.Float64("insure-at", 3, "true count to take insurance at")
+	policyName := fs.String("policy", "textbook", `playing strategy: "dataset" (a counted dataset plays by the true count), "textbook", a reference chart name or a chart file`)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset for the dataset policy")
+	rounds := fs.Int("rounds", 10_000_000, "number of rounds to deal")
+	handsPerHour := fs.Float64("hph", 100, "rounds dealt per hour")
+	seed := fs.Int64("seed", 0, "random seed (0 for a time based seed)")
+	fs.Parse(args)
+
+	switch {
+	case *rounds < 1:
+		return fmt.Errorf("need at least one round")
+	case !(*handsPerHour > 0):
+		return fmt.Errorf("-hph %g: rounds per hour must be positive", *handsPerHour)
+	case *min <= 0 || *max < *min:
+		return fmt.Errorf("table limits %g - %g are invalid", *min, *max)
+	}
+	table, bets, policy, count, err := playerFlags(*decks, *penetration, *system, *rampSpec, *min, *max,
+		*policyName, *dataFile)
+	if err != nil {
+		return err
+	}
+	bets.Wong, bets.WongIn, bets.WongOut = *wong, *wongIn, *wongOut
+	bets.Insure, bets.InsureAt = *insure, *insureAt
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng, _ := game.NewRand(*seed)
+	player, err := betting.NewPlayer(table, bets, policy, count, rng)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d decks, %.0f%% penetration, %s count, %s strategy, seed %d\n", table.Decks, 100*table.Penetration,
+		count.System.Name, *policyName, *seed)
+	fmt.Printf("Bets: %s, table %g - %g", bets.Ramp, table.Min, table.Max)
+	if bets.Wong {
+		fmt.Printf(", wonging in at %+g, out below %+g", bets.WongIn, bets.WongOut)
+	}
+	if bets.Insure {
+		fmt.Printf(", insurance from %+g", bets.InsureAt)
+	}
+	fmt.Printf("\nDealing %d rounds...\n", *rounds)
+	betting.Spread(player, *rounds, *handsPerHour).Print()
+	return nil
+}
+
+// playerFlags builds the table, bet ramp, playing policy and counter of a
+// counting player. A counted dataset policy reads the counter, so it must be
+// counted with the same system.
+func playerFlags(decks int, penetration float64, system, rampSpec string, min, max float64,
+	policyName, dataFile string) (betting.Table, betting.Bets, sim.Policy, *counting.Counter, error) {
+	table := betting.Table{Decks: decks, Penetration: penetration, Min: min, Max: max}
+	ramp, err := betting.ParseRamp(rampSpec)
+	if err != nil {
+		return table, betting.Bets{}, nil, nil, err
+	}
+	bets := betting.Bets{Ramp: ramp}
+	s, err := counting.Lookup(system)
+	if err != nil {
+		return table, bets, nil, nil, err
+	}
+	count := counting.NewCounter(s, decks*52)
+
+	var policy sim.Policy
+	switch policyName {
+	case "dataset":
+		dataset, err := sim.LoadDataset(dataFile)
+		if err != nil {
+			return table, bets, nil, nil, err
+		}
+		meta := dataset.Metadata()
+		if dataset.CountRange() > 0 && meta.Count != s.Name {
+			return table, bets, nil, nil, fmt.Errorf("%s is counted with %s, not %s", dataFile, meta.Count, s.Name)
+		}
+		fmt.Printf("Dataset %s: %s\n", dataFile, meta)
+		policy = sim.DatasetPolicy{Data: dataset, Counter: &count}
+	default:
+		chart, err := loadChart(policyName)
+		if err != nil {
+			return table, bets, nil, nil, err
+		}
+		policy = chart
+	}
+	return table, bets, policy, &count, nil
+}
+
+// bankroll - risk of ruin, session results and drawdowns of a bet policy from a bankroll
+func cmdBankroll(args []string) error {
+	fs := flag.NewFlagSet("bankroll", flag.ExitOnError)
+	decks := fs.Int("decks", 6, "number of decks in the shoe")
+	penetration := fs.Float64("penetration", 0.75, "share of the shoe dealt before the shuffle")
+	system := fs.String("count", counting.HiLo.Name, strings.Join(counting.Names(), ", ")+" or ten tags for A,2..9,T")
+	rampSpec := fs.String("ramp", "1:2,2:4,3:8,4:12", `bet ramp "count:units,..." in table minimums (1 unit below the first step)`)
+	min := fs.Float64("min", 10, "table minimum bet (one unit)")
+	max := fs.Float64("max", 500, "table maximum bet")
+	wong := fs.Bool("wong", false, "wong: join at -wong-in, leave below -wong-out until the shuffle")
+	wongIn := fs.Float64("wong-in", 1, "true count to join the table at when wonging")
+	wongOut := fs.Float64("wong-out", -1, "true count to leave the table below when wonging")
+	kelly := fs.Float64("kelly", 0, "bet this fraction of the Kelly bet instead of the ramp, e.g. 1 (full) or 0.5 (0: ramp)")
+	calibrate := fs.Int("calibrate", 5_000_000, "flat bet rounds measuring the edge and variance by count for -kelly")
+	policyName := fs.String("policy", "textbook", `playing strategy: "dataset" (a counted dataset plays by the true count), "textbook", a reference chart name or a chart file`)
+	dataFile := fs.String("data", "bj_sim_data.json", "dataset for the dataset policy")
+	bankroll := fs.Float64("bankroll", 10000, "starting bankroll of every session")
+	hours := fs.Float64("hours", 4, "session length in hours")
+	sessions := fs.Int("sessions", 10000, "number of sessions")
+	handsPerHour := fs.Float64("hph", 100, "rounds dealt per hour")
+	seed := fs.Int64("seed", 0, "random seed (0 for a time based seed)")
+	fs.Parse(args)
+
+	table, bets, policy, count, err := playerFlags(*decks, *penetration, *system, *rampSpec, *min, *max,
+		*policyName, *dataFile)
+	if err != nil {
+		return err
+	}
+	bets.Wong, bets.WongIn, bets.WongOut = *wong, *wongIn, *wongOut
+	rounds := int(*hours * *handsPerHour)
+	switch {
+	case *bankroll < table.Min:
+		return fmt.Errorf("a bankroll of %g cannot make the table minimum %g", *bankroll, table.Min)
+	case !(*handsPerHour > 0):
+		return fmt.Errorf("-hph %g: rounds per hour must be positive", *handsPerHour)
+	case rounds < 1 || *sessions < 1:
+		return fmt.Errorf("need at least one session of one round")
+	case *kelly < 0:
+		return fmt.Errorf("-kelly must not be negative")
+	case *kelly > 0 && *calibrate < 1:
+		return fmt.Errorf("-calibrate %d: -kelly needs calibration rounds", *calibrate)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng, _ := game.NewRand(*seed)
+
+	fmt.Printf("%d decks, %.0f%% penetration, %s count, %s strategy, seed %d\n", table.Decks, 100*table.Penetration,
+		count.System.Name, *policyName, *seed)
+	if *kelly > 0 {
+		flat, err := betting.NewPlayer(table, betting.Bets{}, policy, count, rng)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Calibrating the edge by count over %d flat bet rounds...\n", *calibrate)
+		bets.Kelly = betting.NewKelly(betting.Spread(flat, *calibrate, *handsPerHour), *kelly)
+		fmt.Printf("\nKelly bets for a bankroll of %g (%g Kelly):\n", *bankroll, *kelly)
+		bets.Kelly.Print(*bankroll, table.Min, table.Max)
+		fmt.Printf("\nBets: %g Kelly on the current bankroll in whole minimums, table %g - %g, Kelly bets below one minimum sat out", *kelly, table.Min, table.Max)
+	} else {
+		fmt.Printf("Bets: %s, table %g - %g", bets.Ramp, table.Min, table.Max)
+	}
+	if bets.Wong {
+		fmt.Printf(", wonging in at %+g, out below %+g", bets.WongIn, bets.WongOut)
+	}
+	fmt.Println()
+
+	player, err := betting.NewPlayer(table, bets, policy, count, rng)
+	if err != nil {
+		return err
+	}
+	betting.Sessions(player, *bankroll, rounds, *sessions, *handsPerHour).Print()
+	return nil
+}
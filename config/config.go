@@ -3,11 +3,29 @@ package config
 import (
 	"flag"
 	"os"
+	"runtime"
+	"time"
 )
 
 // Config holds application configuration
 type Config struct {
 	DebugMode bool
+	InfiniteDeck bool // simulate with an infinite deck instead of a single shuffled deck
+	Decks int // decks in the shoe
+	Count string // counting system of a counted simulation (shoes dealt over many rounds, "" for none)
+	CountRange int // true count buckets -CountRange .. CountRange of a counted simulation
+	Penetration float64 // share of the shoe dealt before the shuffle in a counted simulation
+	Workers int // number of simulation goroutines
+	Seed int64 // base random seed for the simulation workers (0: time based)
+	DataFile string // dataset loaded at the start of a simulation
+	OutFile string // dataset written by the simulation
+	Snapshots int // rotated copies of OutFile kept on every save (0: none)
+	CheckpointFile string // checkpoint written alongside every dataset save
+	Resume bool // continue the run saved in CheckpointFile
+	Hands int // number of hands to simulate
+	Duration time.Duration // time budget for the simulation (0: no limit)
+	Confidence float64 // stop once every cell's best action is resolved at this confidence (0: off)
+	StdErrTarget float64 // stop once every cell's standard error is below this (0: off)
 }
 
 // Global configuration instance
@@ -17,8 +35,32 @@ var AppConfig Config
 func Init() {
 	// Command line flags
 	debugFlag := flag.Bool("debug", false, "Enable debug mode for detailed output")
+	flag.BoolVar(&AppConfig.InfiniteDeck, "infinite", false, "Simulate with an infinite deck (1/13 card probabilities)")
+	flag.IntVar(&AppConfig.Decks, "decks", 1, "Number of decks in the shoe")
+	flag.StringVar(&AppConfig.Count, "count", "", "Counting system (hilo, ko, hiopt1, hiopt2, omega2, zen, halves or ten tags for A,2..9,T): deal shoes over many rounds and record decisions by true count")
+	flag.IntVar(&AppConfig.CountRange, "tc-range", 6, "True count buckets recorded by a counted simulation (-n .. +n, beyond in the edge buckets)")
+	flag.Float64Var(&AppConfig.Penetration, "penetration", 0.75, "Share of the shoe dealt before the shuffle in a counted simulation")
+	flag.IntVar(&AppConfig.Workers, "workers", runtime.GOMAXPROCS(0), "Number of simulation worker goroutines")
+	flag.Int64Var(&AppConfig.Seed, "seed", 0, "Random seed for the simulation (0 for a time based seed)")
+	flag.StringVar(&AppConfig.DataFile, "in", "bj_sim_data.json", "Dataset to continue simulating into")
+	flag.StringVar(&AppConfig.OutFile, "out", "", "Dataset file written by the simulation (default: the -in file)")
+	flag.IntVar(&AppConfig.Snapshots, "snapshots", 0, "Number of rotated snapshots of the dataset to keep (out.1 newest)")
+	flag.StringVar(&AppConfig.CheckpointFile, "checkpoint", "bj_sim_checkpoint.json", "Checkpoint file for resuming simulations (<out>.checkpoint when -in/-out name another dataset)")
+	flag.BoolVar(&AppConfig.Resume, "resume", false, "Resume the simulation saved in the checkpoint file")
+	flag.IntVar(&AppConfig.Hands, "hands", 1_000_000_000, "Number of hands to simulate")
+	flag.DurationVar(&AppConfig.Duration, "duration", 0, "Stop the simulation after this long, e.g. 2h (0 for no limit)")
+	flag.Float64Var(&AppConfig.Confidence, "confidence", 0, "Stop when every cell's best vs second best action is resolved at this confidence, e.g. 0.95")
+	flag.Float64Var(&AppConfig.StdErrTarget, "stderr", 0, "Stop when every cell's standard error is below this threshold")
 	flag.Parse()
 
+	if AppConfig.OutFile == "" {
+		AppConfig.OutFile = AppConfig.DataFile
+	}
+	// separate experiments get separate checkpoints unless one is given
+	if !IsSet("checkpoint") && AppConfig.OutFile != "bj_sim_data.json" {
+		AppConfig.CheckpointFile = AppConfig.OutFile + ".checkpoint"
+	}
+
 	// Check environment variable
 	debugEnv := os.Getenv("BLACKJACK_DEBUG")
 	
@@ -26,6 +68,15 @@ func Init() {
 	AppConfig.DebugMode = *debugFlag || (debugEnv == "true" || debugEnv == "1")
 }
 
+// IsSet reports whether the flag name was given on the command line
+func IsSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
 // IsDebugMode returns whether debug mode is enabled
 func IsDebugMode() bool {
 	return AppConfig.DebugMode
@@ -0,0 +1,287 @@
+/*
+Card counting
+
+A System tags every card value with a count; the running count is the sum of the
+tags of the cards seen. Balanced systems divide it by the decks remaining in the
+shoe to get the true count, which estimates the advantage of the cards left.
+Unbalanced systems (KO) start the shoe at an initial running count instead; their
+true count first takes out the drift expected from the cards seen.
+
+Ace neutral systems (Hi-Opt I/II, Omega II) tag the ace 0. Their players keep a
+side count of the aces and add the ace surplus of the cards left to the count
+they bet by (Counter.BettingCount).
+*/
+package counting
+
+import (
+	"blackjack/game"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// System - a card counting system
+type System struct {
+	Name      string
+	Tags      [11]float64 // tag per card value 1 (ace) .. 10 (tens and faces)
+	AceWeight float64     // betting count added per surplus ace per deck remaining (ace neutral systems)
+}
+
+// HiLo - the Hi-Lo count: 2-6 +1, 7-9 0, tens and aces -1
+var HiLo = System{
+	Name: "hilo",
+	Tags: [11]float64{1: -1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1, 10: -1},
+}
+
+// KO - Knock-Out: Hi-Lo with the 7 counted +1, unbalanced
+var KO = System{
+	Name: "ko",
+	Tags: [11]float64{1: -1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1, 7: 1, 10: -1},
+}
+
+// HiOptI - Hi-Opt I: 3-6 +1, tens -1, ace neutral
+var HiOptI = System{
+	Name:      "hiopt1",
+	Tags:      [11]float64{3: 1, 4: 1, 5: 1, 6: 1, 10: -1},
+	AceWeight: 1,
+}
+
+// HiOptII - Hi-Opt II: 2, 3, 6, 7 +1, 4, 5 +2, tens -2, ace neutral
+var HiOptII = System{
+	Name:      "hiopt2",
+	Tags:      [11]float64{2: 1, 3: 1, 4: 2, 5: 2, 6: 1, 7: 1, 10: -2},
+	AceWeight: 2,
+}
+
+// OmegaII - Omega II: 2, 3, 7 +1, 4-6 +2, 9 -1, tens -2, ace neutral
+var OmegaII = System{
+	Name:      "omega2",
+	Tags:      [11]float64{2: 1, 3: 1, 4: 2, 5: 2, 6: 2, 7: 1, 9: -1, 10: -2},
+	AceWeight: 2,
+}
+
+// Zen - the Zen count: 2, 3, 7 +1, 4-6 +2, tens -2, aces -1
+var Zen = System{
+	Name: "zen",
+	Tags: [11]float64{1: -1, 2: 1, 3: 1, 4: 2, 5: 2, 6: 2, 7: 1, 10: -2},
+}
+
+// WongHalves - Wong Halves: 2 and 7 +0.5, 3, 4, 6 +1, 5 +1.5, 9 -0.5, tens and aces -1
+var WongHalves = System{
+	Name: "halves",
+	Tags: [11]float64{1: -1, 2: 0.5, 3: 1, 4: 1, 5: 1.5, 6: 1, 7: 0.5, 9: -0.5, 10: -1},
+}
+
+// systems by name (see Lookup)
+var systems = map[string]System{
+	HiLo.Name:       HiLo,
+	KO.Name:         KO,
+	HiOptI.Name:     HiOptI,
+	HiOptII.Name:    HiOptII,
+	OmegaII.Name:    OmegaII,
+	Zen.Name:        Zen,
+	WongHalves.Name: WongHalves,
+}
+
+// Names lists the counting systems Lookup knows
+func Names() []string {
+	names := make([]string, 0, len(systems))
+	for name := range systems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns a counting system by name, e.g. "hilo", or a user defined one
+// given by its ten tags for A, 2 .. 9, T, e.g. "-1,1,1,1,1,1,0,0,0,-1"
+func Lookup(name string) (System, error) {
+	if strings.Contains(name, ",") {
+		return Custom(name)
+	}
+	s, ok := systems[strings.ToLower(name)]
+	if !ok {
+		return System{}, fmt.Errorf("unknown counting system %q (have %s, or ten tags A,2..9,T)", name, strings.Join(Names(), ", "))
+	}
+	return s, nil
+}
+
+// Custom returns the system with the ten comma separated tags for A, 2 .. 9, T.
+// Its name is the tag list, so Lookup finds it again from a dataset's metadata.
+// An ace tagged 0 gets the side count of the ace neutral systems at weight 1.
+func Custom(tags string) (System, error) {
+	parts := strings.Split(tags, ",")
+	if len(parts) != 10 {
+		return System{}, fmt.Errorf("counting tags %q: need 10 values for A, 2 .. 9, T, got %d", tags, len(parts))
+	}
+	s := System{}
+	names := make([]string, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return System{}, fmt.Errorf("counting tags %q: invalid tag %q", tags, part)
+		}
+		s.Tags[i+1] = v
+		names[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	s.Name = strings.Join(names, ",")
+	if s.Tags[1] == 0 {
+		s.AceWeight = 1
+	}
+	return s, nil
+}
+
+// Tag returns the count of a card
+func (s System) Tag(c game.Card) float64 {
+	if c.Rank > 10 {
+		return s.Tags[10]
+	}
+	return s.Tags[c.Rank]
+}
+
+// DeckSum returns the running count of a whole 52 card deck: 0 for balanced systems
+func (s System) DeckSum() float64 {
+	sum := 4 * s.Tags[10] * 4
+	for v := 1; v <= 9; v++ {
+		sum += 4 * s.Tags[v]
+	}
+	return sum
+}
+
+// Balanced reports whether a whole deck counts to 0
+func (s System) Balanced() bool {
+	return s.DeckSum() == 0
+}
+
+// minRemaining - the decks remaining never count as less than a quarter deck,
+// so the last cards of a shoe do not blow the true count up
+const minRemaining = 13
+
+// TrueCount converts a running count to the count per deck remaining
+func TrueCount(running float64, cardsRemaining int) float64 {
+	if cardsRemaining < minRemaining {
+		cardsRemaining = minRemaining
+	}
+	return running * 52 / float64(cardsRemaining)
+}
+
+// Bucket returns the true count bucket of tc: its floor, with counts beyond
+// ±countRange in the edge buckets. Bucket n holds the true counts n <= tc < n+1.
+func Bucket(tc float64, countRange int) int {
+	b := int(math.Floor(tc))
+	if b < -countRange {
+		return -countRange
+	}
+	if b > countRange {
+		return countRange
+	}
+	return b
+}
+
+// ----------------------------------------------------------------------------
+// Counter
+
+// Counter keeps the count of a shoe as its cards are seen. It is a game.Observer,
+// so Watch hooks it into the dealing of a deck. A Counter is a plain value:
+// copying it forks the count, e.g. to look at a round without keeping it.
+type Counter struct {
+	System System
+
+	Running float64 // running count, from the initial running count of the shoe
+	Cards   int     // cards in the shoe, 0 for an infinite deck (no count)
+	Dealt   int     // cards seen since the shuffle
+	Aces    int     // aces seen since the shuffle (side count)
+}
+
+// NewCounter returns a counter of system for a shoe of cards cards
+func NewCounter(system System, cards int) Counter {
+	c := Counter{System: system}
+	c.Reset(cards)
+	return c
+}
+
+// Watch starts counting a freshly shuffled deck and makes the counter its observer
+func (c *Counter) Watch(deck *game.Deck) {
+	if deck.Infinite {
+		c.Reset(0)
+	} else {
+		c.Reset(len(deck.Cards))
+	}
+	deck.Observer = c
+}
+
+// Reset starts a new shoe of cards cards. Unbalanced systems start at the
+// initial running count that ends a full shoe on the count of one deck, e.g. 4 - 4
+// per deck for KO.
+func (c *Counter) Reset(cards int) {
+	c.Cards, c.Dealt, c.Aces = cards, 0, 0
+	c.Running = -c.System.DeckSum() * (float64(cards)/52 - 1)
+}
+
+// Seen counts a card (game.Observer)
+func (c *Counter) Seen(card game.Card) {
+	c.Running += c.System.Tag(card)
+	c.Dealt++
+	if card.Rank == 1 {
+		c.Aces++
+	}
+}
+
+// Remaining returns the cards not seen yet (0 for an infinite deck)
+func (c *Counter) Remaining() int {
+	if c.Cards == 0 || c.Dealt >= c.Cards {
+		return 0
+	}
+	return c.Cards - c.Dealt
+}
+
+// DecksRemaining returns the decks not seen yet, never less than a quarter deck
+func (c *Counter) DecksRemaining() float64 {
+	remaining := c.Cards - c.Dealt
+	if remaining < minRemaining {
+		remaining = minRemaining
+	}
+	return float64(remaining) / 52
+}
+
+// True returns the true count: the running count per deck remaining, less the
+// drift an unbalanced system shows on average after the cards seen. Always 0
+// for an infinite deck.
+func (c *Counter) True() float64 {
+	if c.Cards == 0 {
+		return 0
+	}
+	expected := -c.System.DeckSum()*(float64(c.Cards)/52-1) + c.System.DeckSum()*float64(c.Dealt)/52
+	return TrueCount(c.Running-expected, c.Cards-c.Dealt)
+}
+
+// AceSurplus returns the aces left in the shoe beyond the 4 per deck remaining
+// of an even shoe - positive when the rest of the shoe is rich in aces
+func (c *Counter) AceSurplus() float64 {
+	if c.Cards == 0 {
+		return 0
+	}
+	return float64(c.Cards/13-c.Aces) - 4*float64(c.Cards-c.Dealt)/52
+}
+
+// BettingCount returns the true count adjusted by the ace side count, per deck
+// remaining, for systems that leave the ace out of the count
+func (c *Counter) BettingCount() float64 {
+	if c.Cards == 0 {
+		return 0
+	}
+	return c.True() + c.System.AceWeight*c.AceSurplus()/c.DecksRemaining()
+}
+
+func (c Counter) String() string {
+	if c.Cards == 0 {
+		return fmt.Sprintf("%s running %+g (infinite deck, no true count)", c.System.Name, c.Running)
+	}
+	s := fmt.Sprintf("%s running %+g, true %+.1f, %.1f decks left", c.System.Name, c.Running, c.True(), float64(c.Remaining())/52)
+	if c.System.AceWeight != 0 {
+		s += fmt.Sprintf(", aces %+.1f (betting %+.1f)", c.AceSurplus(), c.BettingCount())
+	}
+	return s
+}
@@ -0,0 +1,172 @@
+package counting
+
+import (
+	"blackjack/game"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDeckSum(t *testing.T) {
+	tests := []struct {
+		system System
+		sum    float64
+	}{
+		{HiLo, 0},
+		{KO, 4},
+		{HiOptI, 0},
+		{HiOptII, 0},
+		{OmegaII, 0},
+		{Zen, 0},
+		{WongHalves, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.system.DeckSum(); got != tt.sum {
+			t.Errorf("%s: deck sum %g, want %g", tt.system.Name, got, tt.sum)
+		}
+		if got := tt.system.Balanced(); got != (tt.sum == 0) {
+			t.Errorf("%s: balanced %v", tt.system.Name, got)
+		}
+	}
+}
+
+// counting a whole shoe ends on the sum of one deck: 0 for balanced systems,
+// and for unbalanced ones too once they start at the initial running count
+func TestCountWholeShoe(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range Names() {
+		s, _ := Lookup(name)
+		for _, decks := range []int{1, 6} {
+			deck := game.NewShoe(decks, rng)
+			var c Counter
+			c.System = s
+			c.Watch(&deck)
+			if decks > 1 && !s.Balanced() && c.Running == 0 {
+				t.Errorf("%s %dD: unbalanced count starts at 0", name, decks)
+			}
+			for _, card := range deck.Cards {
+				c.Seen(card)
+			}
+			if c.Running != s.DeckSum() || c.Aces != 4*decks || c.Remaining() != 0 {
+				t.Errorf("%s %dD: running %g, aces %d, remaining %d after the shoe, want %g, %d, 0",
+					name, decks, c.Running, c.Aces, c.Remaining(), s.DeckSum(), 4*decks)
+			}
+		}
+	}
+}
+
+// an unbalanced system's true count takes out the drift of the cards seen: an
+// even part of the shoe leaves it at 0 like a balanced count
+func TestTrueCountUnbalanced(t *testing.T) {
+	c := NewCounter(KO, 6*52)
+	for v := 1; v <= 13; v++ { // one of each rank: a neutral quarter deck
+		c.Seen(game.Card{Rank: v})
+	}
+	if got := c.True(); math.Abs(got) > 1e-12 {
+		t.Errorf("KO after an even quarter deck: true count %g, want 0", got)
+	}
+	if got := c.Running; got != -20+1 {
+		t.Errorf("KO after an even quarter deck: running %g, want -19", got)
+	}
+}
+
+func TestTrueCount(t *testing.T) {
+	tests := []struct {
+		running   float64
+		remaining int
+		want      float64
+	}{
+		{2, 104, 1},
+		{-3, 156, -1},
+		{6, 26, 12},
+		{3, 5, 12}, // never less than a quarter deck
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := TrueCount(tt.running, tt.remaining); got != tt.want {
+			t.Errorf("TrueCount(%g, %d) = %g, want %g", tt.running, tt.remaining, got, tt.want)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	tests := []struct {
+		tc   float64
+		rng  int
+		want int
+	}{
+		{0, 6, 0},
+		{0.99, 6, 0},
+		{-0.01, 6, -1},
+		{1, 6, 1},
+		{-1, 6, -1},
+		{-1.5, 6, -2},
+		{5.99, 6, 5},
+		{7.5, 6, 6},
+		{-9, 6, -6},
+		{3, 2, 2},
+		{0.5, 0, 0},
+		{-0.5, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Bucket(tt.tc, tt.rng); got != tt.want {
+			t.Errorf("Bucket(%g, %d) = %d, want %d", tt.tc, tt.rng, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		spec      string
+		tags      [11]float64
+		aceWeight float64
+		err       bool
+	}{
+		{spec: "hilo", tags: HiLo.Tags},
+		{spec: "HiLo", tags: HiLo.Tags},
+		{spec: "hiopt2", tags: HiOptII.Tags, aceWeight: 2},
+		{spec: "-1,1,1,1,1,1,0,0,0,-1", tags: HiLo.Tags},
+		{spec: " 0, 1,1,1,1,1,0,0,0,-1", tags: [11]float64{2: 1, 3: 1, 4: 1, 5: 1, 6: 1, 10: -1}, aceWeight: 1},
+		{spec: "-1,0.5,1,1,1.5,1,0.5,0,-0.5,-1", tags: WongHalves.Tags},
+		{spec: "wonder", err: true},
+		{spec: "1,2,3", err: true},
+		{spec: "-1,1,1,1,1,1,0,0,0,x", err: true},
+	}
+	for _, tt := range tests {
+		s, err := Lookup(tt.spec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Lookup(%q): no error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", tt.spec, err)
+			continue
+		}
+		if s.Tags != tt.tags || s.AceWeight != tt.aceWeight {
+			t.Errorf("Lookup(%q) = %+v, want tags %v, ace weight %g", tt.spec, s, tt.tags, tt.aceWeight)
+		}
+		// a dataset records the name: it must find the same system again
+		if again, err := Lookup(s.Name); err != nil || again != s {
+			t.Errorf("Lookup(%q) again from %q = %+v, %v", tt.spec, s.Name, again, err)
+		}
+	}
+}
+
+func TestAceSurplus(t *testing.T) {
+	c := NewCounter(HiOptI, 52)
+	if got := c.AceSurplus(); got != 0 {
+		t.Errorf("fresh deck: ace surplus %g", got)
+	}
+	for i := 0; i < 4; i++ {
+		c.Seen(game.Card{Rank: 5})
+	}
+	// 4 aces left in 48 cards, 3.69 expected
+	if got, want := c.AceSurplus(), 4-4*48.0/52; math.Abs(got-want) > 1e-12 {
+		t.Errorf("after four fives: ace surplus %g, want %g", got, want)
+	}
+	if got, want := c.BettingCount(), c.True()+c.AceSurplus()/c.DecksRemaining(); got != want {
+		t.Errorf("betting count %g, want %g", got, want)
+	}
+}
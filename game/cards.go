@@ -9,62 +9,153 @@ import (
 // ============================================================================
 // Deck structures
 
+// Observer sees the cards of a deck as they are exposed on the table, e.g. a
+// card counter (see the counting package)
+type Observer interface {
+	Seen(card Card)
+}
+
 type Deck struct {
-	Cards [52]Card
+	Cards []Card // one or more 52 card decks, never changed once shuffled (copies share it)
 
 	Drawn int // number of cards drawn from the deck
 	// i.e. next card to be drawn is at index Drawn
+
+	// infinite deck - every card is drawn with probability 1/13 (Cards unused)
+	// the n-th card is derived from seed so copies of the deck draw the same cards
+	Infinite bool
+	seed     uint64
+
+	// told of every card dealt face up, and of the dealer's hole card once it is
+	// turned. Copies (what-if branches of a simulation) have no observer.
+	Observer Observer
+}
+
+// DrawSource selects where a GameState draws its cards from
+type DrawSource int
+
+const (
+	SingleDeck   DrawSource = iota // shuffled 52 card deck
+	InfiniteDeck                   // independent draws, 1/13 per rank
+)
+
+// NewDeck creates a deck for the given draw source, shuffled with rng
+// (nil uses the global math/rand generator)
+func NewDeck(source DrawSource, rng *rand.Rand) Deck {
+	if source == InfiniteDeck {
+		return newInfiniteDeck(rng)
+	}
+	return newShuffledDeck(rng)
+}
+
+func newInfiniteDeck(rng *rand.Rand) Deck {
+	seed := rand.Uint64
+	if rng != nil {
+		seed = rng.Uint64
+	}
+	return Deck{
+		Infinite: true,
+		seed:     seed(),
+	}
 }
 
 func newDeck() Deck {
-	// make a new deck of cards
-	var deck Deck
+	return newShuffledDeck(nil)
+}
+
+func newShuffledDeck(rng *rand.Rand) Deck {
+	return NewShoe(1, rng)
+}
 
+// NewShoe creates a shoe of decks 52 card decks shuffled together with rng
+// (nil uses the global math/rand generator). A one deck shoe is the SingleDeck deck.
+func NewShoe(decks int, rng *rand.Rand) Deck {
+	if decks < 1 {
+		decks = 1
+	}
+	// make the decks of cards
+	deck := Deck{Cards: make([]Card, 0, decks*52)}
+	for d := 0; d < decks; d++ {
 		for suit := 0; suit < 4; suit++ {
 			for rank := 1; rank <= 13; rank++ {
-				deck.Cards[suit*13+rank-1] = Card{Suit: suit, Rank: rank}
+				deck.Cards = append(deck.Cards, Card{Suit: suit, Rank: rank})
 			}
 		}
+	}
 
 	// shuffle the deck
-	deck.shuffle()
+	deck.shuffle(rng)
 
 	return deck
 }
 
-// Draw a card from the deck
+// Draw a card from the deck. A round that runs past the end of a shoe dealt
+// over many rounds draws the discards again, in their order.
 func (deck *Deck) Draw() Card {
-	if deck.Drawn >= 52 {
-		panic("Error: No more cards to draw from the deck.")
+	if deck.Infinite {
+		card := infiniteCard(deck.seed, deck.Drawn)
+		deck.Drawn++
+		return card
+	}
+
+	if len(deck.Cards) == 0 {
+		panic("Error: No cards to draw from the deck.")
 	}
 
 	// get the next card to draw
-	card := deck.Cards[deck.Drawn]
+	card := deck.Cards[deck.Drawn%len(deck.Cards)]
 	deck.Drawn++
 
 	return card
 }
 
-// Copy creates a deep copy of the Deck
+// drawFaceUp draws a card everyone at the table sees
+func (deck *Deck) drawFaceUp() Card {
+	card := deck.Draw()
+	deck.expose(card)
+	return card
+}
+
+// expose tells the observer of a card turned face up
+func (deck *Deck) expose(card Card) {
+	if deck.Observer != nil {
+		deck.Observer.Seen(card)
+	}
+}
+
+// Remaining returns the number of cards left to draw (0 for an infinite deck)
+func (deck *Deck) Remaining() int {
+	if deck.Infinite || deck.Drawn >= len(deck.Cards) {
+		return 0
+	}
+	return len(deck.Cards) - deck.Drawn
+}
+
+// Copy creates a copy of the Deck (the shuffled cards are shared, never changed)
+// without the observer - what the copy deals is not seen at the table
 func (deck *Deck) Copy() Deck {
 	newDeck := Deck{
-		Drawn: deck.Drawn,
+		Cards:    deck.Cards,
+		Drawn:    deck.Drawn,
+		Infinite: deck.Infinite,
+		seed:     deck.seed,
 	}
 	
-	// Copy the cards array
-	newDeck.Cards = deck.Cards // This creates a copy since Cards is an array, not a slice
-	
 	return newDeck
 }
 
 // shuffle the order of the cards in the deck
-func (deck *Deck) shuffle() {
+func (deck *Deck) shuffle(rng *rand.Rand) {
 
-	// create a random sample of indices 0 to 51
-	indices := rand.Perm(len(deck.Cards))
+	// create a random sample of indices 0 to len-1
+	perm := rand.Perm
+	if rng != nil {
+		perm = rng.Perm
+	}
+	indices := perm(len(deck.Cards))
 
 	// create a new deck to hold the shuffled cards
-	var shuffledDeck [52]Card
+	shuffledDeck := make([]Card, len(deck.Cards))
 	for i, index := range indices {
 		shuffledDeck[i] = deck.Cards[index]
 	}
@@ -72,6 +163,17 @@ func (deck *Deck) shuffle() {
 	deck.Cards = shuffledDeck
 }
 
+// infiniteCard returns the n-th card of an infinite deck (splitmix64 hash of seed + n)
+func infiniteCard(seed uint64, n int) Card {
+	x := seed + uint64(n+1)*0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	x ^= x >> 31
+
+	i := int(x % 52)
+	return Card{Suit: i / 13, Rank: i%13 + 1}
+}
+
 // Print human format deck - only for debugging purposes
 func (deck Deck) Print() {
 	for i, card := range deck.Cards {
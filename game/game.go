@@ -1,6 +1,7 @@
 package game
 
 import (
+	"math/rand"
 	"strconv"
 )
 
@@ -74,8 +75,8 @@ func (gs *GameState) ActionCalc(playerMove int) {
 		}
 
 		// Create two new hands, each with one of the split cards
-		newHand1 := []Card{hand[0], gs.Deck.Draw()}
-		newHand2 := []Card{hand[1], gs.Deck.Draw()}
+		newHand1 := []Card{hand[0], gs.Deck.drawFaceUp()}
+		newHand2 := []Card{hand[1], gs.Deck.drawFaceUp()}
 
 		// Replace the current hand with the first new hand
 		gs.PlayerHand[gs.HandToPlay] = newHand1
@@ -121,9 +122,21 @@ func (gs *GameState) ActionCalc(playerMove int) {
 
 // Initialize a new game state
 func StartGame() GameState {
+	return StartGameWith(SingleDeck, nil)
+}
+
+// Initialize a new game state drawing cards from the given source
+// rng shuffles the deck (nil uses the global math/rand generator)
+func StartGameWith(source DrawSource, rng *rand.Rand) GameState {
+	return StartRound(NewDeck(source, rng))
+}
+
+// StartRound deals a new round from deck as it stands - e.g. the next round of
+// a shoe dealt over many rounds. The caller's deck is not advanced.
+func StartRound(deck Deck) GameState {
 
 	gs := GameState{
-		Deck: newDeck(),
+		Deck: deck,
 		// State of play
 		HandToPlay: 0,
 		State:      make([]int, 0), // 0: active game, 1: player win, 2: dealer win, 3: draw, 4: player bust
@@ -211,8 +224,9 @@ func (gs *GameState) UpdatePlayerState() {
 func (gs *GameState) endGame() {
 	// Computes dealer hand/moves + final state computation
 
+	gs.Deck.expose(gs.DealerHand[1]) // hole card turned
 	for (gs.DealerScore < 17) || (gs.dealerAce && gs.DealerScore >= 6) {
-		newCard := gs.Deck.Draw()
+		newCard := gs.Deck.drawFaceUp()
 		gs.DealerHand = append(gs.DealerHand, newCard)
 		gs.DealerScore = calculateScore(gs.DealerHand)
 		// Update dealerAce status
@@ -269,7 +283,7 @@ func (gs *GameState) dealInitialCards() {
 
 	playerHand := make([]Card, 0)
 	for i := 0; i < 2; i++ {
-		playerHand = append(playerHand, gs.Deck.Draw())
+		playerHand = append(playerHand, gs.Deck.drawFaceUp())
 	}
 	// playerHand = append(playerHand,
 	// 			Card{Suit: 0, Rank: 1},
@@ -278,10 +292,8 @@ func (gs *GameState) dealInitialCards() {
 
 	gs.PlayerHand = append(gs.PlayerHand, playerHand)
 	//gs.HandValues = append(gs.HandValues, 1) // ? bug - done in structure
-	// Deal two cards to the dealer
-	for i := 0; i < 2; i++ {
-		gs.DealerHand = append(gs.DealerHand, gs.Deck.Draw())
-	}
+	// Deal two cards to the dealer - the upcard, then the hole card face down
+	gs.DealerHand = append(gs.DealerHand, gs.Deck.drawFaceUp(), gs.Deck.Draw())
 
 	// Update player score and ace status
 	gs.PlayerScore = append(gs.PlayerScore, calculateScore(playerHand))
@@ -292,7 +304,7 @@ func (gs *GameState) dealInitialCards() {
 
 func (gs *GameState) drawCard(hand_ind int) {
 	// draw card into hand
-	new_card := gs.Deck.Draw()
+	new_card := gs.Deck.drawFaceUp()
 	gs.PlayerHand[hand_ind] = append(gs.PlayerHand[hand_ind], new_card)
 }
 
@@ -310,6 +322,17 @@ func calculateScore(hand []Card) int {
 	return score
 }
 
+// HandTotal returns the best total of a hand and whether it is soft (ace counted as 11)
+func HandTotal(hand []Card) (int, bool) {
+	score := calculateScore(hand)
+	for _, card := range hand {
+		if card.Rank == 1 && score <= 11 {
+			return score + 10, true
+		}
+	}
+	return score, false
+}
+
 
 
 // ============================================================================
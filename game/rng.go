@@ -0,0 +1,31 @@
+package game
+
+import "math/rand"
+
+// RandSource is a splitmix64 random source. Unlike the math/rand sources its
+// state is a single exported value, so a run can be saved and resumed exactly.
+type RandSource struct {
+	State uint64
+}
+
+// NewRand returns a generator seeded with seed along with its source
+func NewRand(seed int64) (*rand.Rand, *RandSource) {
+	src := &RandSource{State: uint64(seed)}
+	return rand.New(src), src
+}
+
+func (s *RandSource) Uint64() uint64 {
+	s.State += 0x9E3779B97F4A7C15
+	x := s.State
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+func (s *RandSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *RandSource) Seed(seed int64) {
+	s.State = uint64(seed)
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Table rules
+
+// Rules describes the table rules a game (or a solver) is played under
+type Rules struct {
+	Decks            int     // number of 52 card decks in the shoe
+	DealerHitsSoft17 bool    // H17 when true, S17 otherwise
+	DoubleAfterSplit bool    // DAS - double allowed on post split hands
+	HitSplitAces     bool    // split aces may be played on (otherwise one card each)
+	Surrender        bool    // late surrender on the first two cards
+	DealerPeek       bool    // dealer checks for blackjack (player only loses base bet)
+	BlackjackPays    float64 // payout for a two card 21, 1.0 = even money
+}
+
+// DefaultRules returns the rules the game engine plays by
+func DefaultRules() Rules {
+	return Rules{
+		Decks:            1,
+		DealerHitsSoft17: false,
+		DoubleAfterSplit: true,
+		HitSplitAces:     true,
+		Surrender:        false,
+		DealerPeek:       false,
+		BlackjackPays:    1.0, // BlackJack does NOT pay 3:2
+	}
+}
+
+// String returns a short description of the rules, e.g. "6D S17 DAS 3:2"
+func (r Rules) String() string {
+	parts := []string{fmt.Sprintf("%dD", r.Decks)}
+
+	if r.DealerHitsSoft17 {
+		parts = append(parts, "H17")
+	} else {
+		parts = append(parts, "S17")
+	}
+	if r.DoubleAfterSplit {
+		parts = append(parts, "DAS")
+	} else {
+		parts = append(parts, "noDAS")
+	}
+	if r.HitSplitAces {
+		parts = append(parts, "HSA")
+	}
+	if r.Surrender {
+		parts = append(parts, "LS")
+	}
+	if r.DealerPeek {
+		parts = append(parts, "peek")
+	}
+
+	switch r.BlackjackPays {
+	case 1.5:
+		parts = append(parts, "3:2")
+	case 1.2:
+		parts = append(parts, "6:5")
+	case 1.0:
+		parts = append(parts, "1:1")
+	default:
+		parts = append(parts, fmt.Sprintf("BJ%.2f", r.BlackjackPays))
+	}
+
+	return strings.Join(parts, " ")
+}
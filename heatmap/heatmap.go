@@ -0,0 +1,237 @@
+/*
+Heatmaps of a dataset
+
+For every hand category a grid of player score (rows) against dealer upcard
+(columns) is drawn twice: coloured by the best action (strategy heatmap) and by
+the EV of the best action (EV heatmap). Both render to PNG and SVG using only
+the standard library - the PNG labels use a small built in bitmap font.
+*/
+package heatmap
+
+import (
+	"blackjack/sim"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Kind - what a heatmap colours the cells by
+type Kind int
+
+const (
+	KindStrategy Kind = iota // best action
+	KindEV                   // EV of the best action
+)
+
+// dealer upcards in column order
+var columnUps = []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 1}
+
+// file name stems per category, matching the images in analysis/
+var categoryStems = [sim.CategorySlots]string{"normal_hand", "soft_hand_(ace)", "pair_(split_available)"}
+
+var categoryTitles = [sim.CategorySlots]string{"Hard hands", "Soft hands (ace)", "Pairs (split available)"}
+
+// chart code per action
+var actionCodes = [sim.ActionSlots]string{"S", "H", "D", "P", "R"}
+
+// action colours (same as the strategy command's ANSI output)
+var actionColours = [sim.ActionSlots]color.RGBA{
+	{0xf2, 0xc9, 0x4c, 0xff}, // stand - yellow
+	{0xd6, 0x3a, 0x2f, 0xff}, // hit - red
+	{0x3c, 0xa5, 0x5c, 0xff}, // double - green
+	{0x3a, 0xa8, 0xc1, 0xff}, // split - cyan
+	{0x8c, 0x8c, 0x8c, 0xff}, // surrender - grey
+}
+
+var (
+	unreachedColour  = color.RGBA{0xe6, 0xe6, 0xe6, 0xff}
+	backgroundColour = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	textColour       = color.RGBA{0x20, 0x20, 0x20, 0xff}
+)
+
+// Grid - best action and its EV per player score and dealer upcard of one category
+type Grid struct {
+	Category  int
+	Rows      []int    // player scores (dataset keys), top to bottom
+	RowLabels []string // hand per row e.g. "12", "A,6", "8,8"
+	Best      [][]int  // [row][column] best action, -1 where the dataset has no trials
+	EV        [][]float64
+
+	// [row][column] best action not significantly better than the runner-up
+	// (at sim.DefaultAlpha)
+	Unresolved [][]bool
+}
+
+// FromDataset collects the grid of one hand category from the first decisions
+// (rows the dataset has reached against at least one upcard)
+func FromDataset(sdm sim.SimDataMap, category int) Grid {
+	g := Grid{Category: category}
+	for player := 0; player < sim.PlayerSlots; player++ {
+		reached := false
+		for _, up := range columnUps {
+			cell := sdm.Cell(sim.Context{}, up, player, category)
+			reached = reached || (cell != nil && cell.Reached())
+		}
+		if !reached {
+			continue
+		}
+
+		best := make([]int, len(columnUps))
+		ev := make([]float64, len(columnUps))
+		unresolved := make([]bool, len(columnUps))
+		for col, up := range columnUps {
+			best[col], ev[col] = -1, math.NaN()
+			cell := sdm.Cell(sim.Context{}, up, player, category)
+			if cell == nil {
+				continue
+			}
+			if action, ok := cell.Best(); ok {
+				best[col], ev[col] = action, cell.Data[action].ExpectedValue
+				unresolved[col] = !cell.Significance().Resolved(sim.DefaultAlpha)
+			}
+		}
+		g.Rows = append(g.Rows, player)
+		g.RowLabels = append(g.RowLabels, sim.HandLabel(player, category))
+		g.Best = append(g.Best, best)
+		g.EV = append(g.EV, ev)
+		g.Unresolved = append(g.Unresolved, unresolved)
+	}
+	return g
+}
+
+// evColour maps an EV onto a red (-1) - white (0) - green (+1) scale
+func evColour(ev float64) color.RGBA {
+	if math.IsNaN(ev) {
+		return unreachedColour
+	}
+	t := math.Max(-1, math.Min(1, ev))
+	lerp := func(from, to uint8, f float64) uint8 {
+		return uint8(math.Round(float64(from) + (float64(to)-float64(from))*f))
+	}
+	end := color.RGBA{0x1a, 0x98, 0x50, 0xff}
+	if t < 0 {
+		end, t = color.RGBA{0xd7, 0x30, 0x27, 0xff}, -t
+	}
+	return color.RGBA{lerp(0xff, end.R, t), lerp(0xff, end.G, t), lerp(0xff, end.B, t), 0xff}
+}
+
+// lighten blends a colour halfway to white (unresolved cells)
+func lighten(c color.RGBA) color.RGBA {
+	half := func(v uint8) uint8 {
+		return v + (0xff-v)/2
+	}
+	return color.RGBA{half(c.R), half(c.G), half(c.B), c.A}
+}
+
+// labelColour picks black or white text for a background
+func labelColour(bg color.RGBA) color.RGBA {
+	luminance := 0.299*float64(bg.R) + 0.587*float64(bg.G) + 0.114*float64(bg.B)
+	if luminance < 140 {
+		return color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+	return textColour
+}
+
+// ----------------------------------------------------------------------------
+// Layout - the heatmap as rectangles and text, drawn by the PNG and SVG writers
+
+const (
+	cellW   = 48
+	cellH   = 26
+	marginL = 56
+	marginT = 68
+	marginB = 64
+	marginR = 16
+)
+
+type rect struct {
+	x, y, w, h int
+	fill       color.RGBA
+}
+
+type label struct {
+	x, y   int // centre for centred text, left edge otherwise
+	text   string
+	colour color.RGBA
+	centre bool
+	size   int // pixel scale of the bitmap font (SVG uses it for the font size)
+}
+
+type layout struct {
+	width, height int
+	title         string
+	rects         []rect
+	labels        []label
+}
+
+// build lays out one heatmap of the grid
+func (g Grid) build(kind Kind) layout {
+	l := layout{
+		width:  marginL + cellW*len(columnUps) + marginR,
+		height: marginT + cellH*len(g.Rows) + marginB,
+	}
+	name := "best action"
+	if kind == KindEV {
+		name = "EV of the best action"
+	}
+	l.title = fmt.Sprintf("%s - %s", categoryTitles[g.Category], name)
+	l.labels = append(l.labels, label{x: l.width / 2, y: 16, text: l.title, colour: textColour, centre: true, size: 2})
+
+	// column headers and row labels
+	for col, up := range columnUps {
+		l.labels = append(l.labels, label{x: marginL + col*cellW + cellW/2, y: marginT - 14, text: sim.UpLabel(up), colour: textColour, centre: true, size: 2})
+	}
+	l.labels = append(l.labels, label{x: marginL + cellW*len(columnUps)/2, y: marginT - 36, text: "dealer upcard", colour: textColour, centre: true, size: 1})
+	for row, text := range g.RowLabels {
+		l.labels = append(l.labels, label{x: 6, y: marginT + row*cellH + cellH/2, text: text, colour: textColour, size: 2})
+	}
+
+	// cells
+	for row := range g.Rows {
+		for col := range columnUps {
+			x, y := marginL+col*cellW, marginT+row*cellH
+			fill, text := unreachedColour, ""
+			if action := g.Best[row][col]; action >= 0 {
+				if kind == KindEV {
+					fill, text = evColour(g.EV[row][col]), fmt.Sprintf("%+.2f", g.EV[row][col])
+				} else {
+					fill, text = actionColours[action], actionCodes[action]
+				}
+				if g.Unresolved[row][col] {
+					fill, text = lighten(fill), text+"~"
+				}
+			}
+			l.rects = append(l.rects, rect{x, y, cellW - 1, cellH - 1, fill})
+			if text != "" {
+				l.labels = append(l.labels, label{x: x + cellW/2, y: y + cellH/2, text: text, colour: labelColour(fill), centre: true, size: 2})
+			}
+		}
+	}
+
+	// legend
+	legendY := marginT + cellH*len(g.Rows) + 20
+	if kind == KindEV {
+		for i, ev := range []float64{-1, -0.5, 0, 0.5, 1} {
+			x := marginL + i*cellW*2
+			l.rects = append(l.rects, rect{x, legendY, 20, 16, evColour(ev)})
+			l.labels = append(l.labels, label{x: x + 26, y: legendY + 8, text: fmt.Sprintf("%+.1f", ev), colour: textColour, size: 2})
+		}
+	} else {
+		for action := 0; action < sim.ActionSlots; action++ {
+			x := marginL + action*cellW*2
+			l.rects = append(l.rects, rect{x, legendY, 20, 16, actionColours[action]})
+			l.labels = append(l.labels, label{x: x + 26, y: legendY + 8, text: actionCodes[action] + " " + sim.ActionNames[action], colour: textColour, size: 1})
+		}
+	}
+	l.labels = append(l.labels, label{x: marginL, y: legendY + 32, text: "~ (pale) best action not significantly better than the runner-up", colour: textColour, size: 1})
+	return l
+}
+
+// FileName returns the image name of a grid e.g. "strategy_heatmap_0_normal_hand.png"
+func (g Grid) FileName(kind Kind, ext string) string {
+	prefix := "strategy_heatmap"
+	if kind == KindEV {
+		prefix = "ev_heatmap"
+	}
+	return fmt.Sprintf("%s_%d_%s.%s", prefix, g.Category, categoryStems[g.Category], ext)
+}
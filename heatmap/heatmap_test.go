@@ -0,0 +1,110 @@
+package heatmap
+
+import (
+	"blackjack/sim"
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testGrid() Grid {
+	sdm := sim.CreateSimDataStructure()
+	set := func(up, player int, evs map[int]float64) {
+		for action, ev := range evs {
+			sdm.Set(sim.Key{Dealer: up, Player: player, Action: action}, sim.SimData{ExpectedValue: ev, Trials: 100})
+		}
+	}
+	set(6, 12, map[int]float64{sim.ActionStand: -0.15, sim.ActionHit: -0.25})
+	set(10, 12, map[int]float64{sim.ActionStand: -0.54, sim.ActionHit: -0.47})
+	set(10, 16, map[int]float64{sim.ActionStand: -0.54, sim.ActionHit: -0.53})
+	return FromDataset(sdm, 0)
+}
+
+func TestFromDataset(t *testing.T) {
+	g := testGrid()
+	if len(g.Rows) != 2 || g.Rows[0] != 12 || g.Rows[1] != 16 || g.RowLabels[1] != "16" {
+		t.Fatalf("rows %v %v, want the reached 12 and 16", g.Rows, g.RowLabels)
+	}
+	// columns 2 .. 9, T, A
+	if g.Best[0][4] != sim.ActionStand || g.EV[0][4] != -0.15 || g.Best[0][8] != sim.ActionHit {
+		t.Errorf("12 v 6: %d (%g), 12 v T: %d", g.Best[0][4], g.EV[0][4], g.Best[0][8])
+	}
+	if g.Best[0][0] != -1 || !math.IsNaN(g.EV[0][0]) {
+		t.Errorf("12 v 2 without trials: %d (%g)", g.Best[0][0], g.EV[0][0])
+	}
+	// no variance recorded: the best action cannot be resolved
+	if !g.Unresolved[1][8] {
+		t.Errorf("16 v T without variance is resolved")
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	g := testGrid()
+	for _, kind := range []Kind{KindStrategy, KindEV} {
+		var buf bytes.Buffer
+		if err := g.WritePNG(&buf, kind); err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := g.build(kind)
+		if b := img.Bounds(); b.Dx() != l.width || b.Dy() != l.height {
+			t.Errorf("kind %d: image %v, want %dx%d", kind, b, l.width, l.height)
+		}
+
+		// the corner of a cell has its fill: 12 v 6 (stand, -0.15) and 12 v 2 (no trials)
+		want := actionColours[sim.ActionStand]
+		if kind == KindEV {
+			want = evColour(-0.15)
+		}
+		for _, c := range []struct {
+			col  int
+			want color.RGBA
+		}{{4, lighten(want)}, {0, unreachedColour}} {
+			got := color.RGBAModel.Convert(img.At(marginL+c.col*cellW+1, marginT+1)).(color.RGBA)
+			if got != c.want {
+				t.Errorf("kind %d, column %d: colour %v, want %v", kind, c.col, got, c.want)
+			}
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGrid().WriteSVG(&buf, KindStrategy); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Hard hands - best action") {
+		t.Errorf("no title in the SVG")
+	}
+	dec := xml.NewDecoder(&buf)
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v", err)
+		}
+	}
+}
+
+func TestColours(t *testing.T) {
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	if evColour(0) != white || evColour(math.NaN()) != unreachedColour || evColour(5) != evColour(1) {
+		t.Errorf("EV colours: 0 %v, NaN %v, 5 %v, 1 %v", evColour(0), evColour(math.NaN()), evColour(5), evColour(1))
+	}
+	if labelColour(color.RGBA{0x20, 0x20, 0x20, 0xff}) != white || labelColour(white) != textColour {
+		t.Errorf("labels are not readable on dark and light backgrounds")
+	}
+	if got := testGrid().FileName(KindEV, "png"); got != "ev_heatmap_0_normal_hand.png" {
+		t.Errorf("file name %q", got)
+	}
+}
@@ -0,0 +1,66 @@
+package heatmap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// WritePNG renders the heatmap of kind as a PNG image
+func (g Grid) WritePNG(w io.Writer, kind Kind) error {
+	l := g.build(kind)
+	img := image.NewRGBA(image.Rect(0, 0, l.width, l.height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{backgroundColour}, image.Point{}, draw.Src)
+
+	for _, r := range l.rects {
+		draw.Draw(img, image.Rect(r.x, r.y, r.x+r.w, r.y+r.h), &image.Uniform{r.fill}, image.Point{}, draw.Src)
+	}
+	for _, lb := range l.labels {
+		x, y := lb.x, lb.y-glyphH*lb.size/2
+		if lb.centre {
+			x -= textWidth(lb.text, lb.size) / 2
+		}
+		drawText(img, x, y, lb.text, lb.colour, lb.size)
+	}
+	return png.Encode(w, img)
+}
+
+func hexColour(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// WriteSVG renders the heatmap of kind as an SVG document
+func (g Grid) WriteSVG(w io.Writer, kind Kind) error {
+	l := g.build(kind)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace">`+"\n",
+		l.width, l.height, l.width, l.height)
+	sb.WriteString("<title>")
+	xml.EscapeText(&sb, []byte(l.title))
+	sb.WriteString("</title>\n")
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="%s"/>`+"\n", l.width, l.height, hexColour(backgroundColour))
+
+	for _, r := range l.rects {
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", r.x, r.y, r.w, r.h, hexColour(r.fill))
+	}
+	for _, lb := range l.labels {
+		anchor := "start"
+		if lb.centre {
+			anchor = "middle"
+		}
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" fill="%s" text-anchor="%s" dominant-baseline="central">`,
+			lb.x, lb.y, 6+lb.size*4, hexColour(lb.colour), anchor)
+		xml.EscapeText(&sb, []byte(lb.text))
+		sb.WriteString("</text>\n")
+	}
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"blackjack/config"
+	"blackjack/counting"
 	"blackjack/game"
 	"blackjack/sim"
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 )
 
 // dataset := sim.CreateSimDataStructure() // create the simulation data structure
@@ -16,14 +23,80 @@ func main() {
 	// Initialize configuration
 	config.Init()
 
+	// sub commands e.g. "blackjack solve -decks 6"
+	// (no banner - their output may be piped, e.g. export to stdout)
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(args[0], args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Println("Welcome to the Blackjack Simulator!")
 
-	dataset, _ := sim.LoadFromJSON("bj_sim_data.json")
+	// stop gracefully on SIGINT / SIGTERM or once the time budget is used
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if config.AppConfig.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.AppConfig.Duration)
+		defer cancel()
+	}
+	go func() {
+		<-ctx.Done()
+		stop() // a second signal kills the process
+	}()
+
+	if config.AppConfig.Resume {
+		if err := sim.ResumeBJ(ctx, config.AppConfig.CheckpointFile); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// only a missing file starts a new dataset - a damaged one is reported, never
+	// overwritten by the first save
+	dataset, err := sim.LoadDataset(config.AppConfig.DataFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("Error: cannot load %s: %v\n", config.AppConfig.DataFile, err)
+		os.Exit(1)
+	}
+	countRange := 0
+	if config.AppConfig.Count != "" {
+		countRange = config.AppConfig.CountRange
+	}
+	if err != nil {
+		fmt.Printf("%s does not exist, starting a new dataset\n", config.AppConfig.DataFile)
+		dataset = sim.CreateCountedStructure(countRange)
+	} else {
+		fmt.Printf("Loaded %s: %s\n", config.AppConfig.DataFile, dataset.Metadata())
+	}
+
+	// records under older state keys are never continued (e.g. the bundled legacy
+	// baseline): with a separate -out the run starts a new dataset there instead
+	if meta := dataset.Metadata(); err == nil && meta.KeyVersion < sim.KeyVersion && dataset.TotalTrials() > 0 {
+		if config.AppConfig.OutFile == config.AppConfig.DataFile {
+			fmt.Printf("Error: %s uses older state keys and cannot be continued - simulate into a new dataset, e.g. -out bj_run.json\n",
+				config.AppConfig.DataFile)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(config.AppConfig.OutFile); err == nil {
+			fmt.Printf("Error: %s exists - continue it with -in %s\n", config.AppConfig.OutFile, config.AppConfig.OutFile)
+			os.Exit(1)
+		}
+		fmt.Printf("%s uses older state keys and stays as it is, starting a new dataset\n", config.AppConfig.DataFile)
+		dataset = sim.CreateCountedStructure(countRange)
+	}
 	
 
-	fmt.Println("Beginning simulating bj hands...")
+	fmt.Printf("Beginning simulating bj hands into %s...\n", config.AppConfig.OutFile)
 
-	sim.SimulateBJ(1_000_000_000, dataset)
+	if err := sim.SimulateBJ(ctx, config.AppConfig.Hands, dataset); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 
 
 
@@ -57,18 +130,24 @@ func mainMenuCLI() {
 	}
 }
 
-func blackjackCLI() {
+// blackjackCLI plays one round from deck and moves the deck past it. counter
+// (nil for none) is the deck's observer - its count is shown on every turn.
+func blackjackCLI(deck *game.Deck, counter *counting.Counter) {
 	fmt.Println("=================================================")
 	fmt.Println("Welcome to the Blackjack CLI!")
 	fmt.Println("This is a simple command-line interface for playing Blackjack.")
 
 	reader := bufio.NewReader(os.Stdin)
 	
-	gs := game.StartGame()// Initialize the game state
+	gs := game.StartRound(*deck) // Initialize the game state
+	defer func() { deck.Drawn = gs.Deck.Drawn }()
 	
 	for { // ! START OF TURN LOOP LOGIC
 		// --------------------------------------------
 		gs.Print() // Display the initial game state
+		if counter != nil {
+			fmt.Println("Count:", counter)
+		}
 		ind := gs.HandToPlay
 		fmt.Printf("\n--- Hand %d ---\n", ind+1)
 
@@ -121,15 +200,13 @@ func blackjackCLI() {
 		// after hand is done
 		if gs.HandToPlay + 1 > len(gs.PlayerHand) {
 			bjEndGame(gs)
-			
-			// enter to continue
-			fmt.Println("Press Enter to return to the main menu...")
-			reader := bufio.NewReader(os.Stdin)
-			_, _ = reader.ReadString('\n')
+			if counter != nil {
+				fmt.Println("Count:", counter)
+			}
 			return
 		}
 
-		fmt.Println("Turn loop ended - restarting game loop...\n")
+		fmt.Println("Turn loop ended - restarting game loop...")
 	}
 }
 
@@ -160,7 +237,7 @@ func bjEndGame(gs game.GameState) {
 		fmt.Println(game.PrintCards(hand))
 		fmt.Println("Hand value: ", gs.HandValues[i])
 		fmt.Println("Score: ", gs.PlayerScore[i])
-		fmt.Println("\n")
+		fmt.Println()
 	}
 
 }
\ No newline at end of file
@@ -0,0 +1,298 @@
+package report
+
+import (
+	"blackjack/heatmap"
+	"blackjack/sim"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// Options - what goes into a report
+type Options struct {
+	Title    string
+	DataFile string
+	Top      int // best / worst situations listed
+}
+
+// colours per action, as in the heatmaps
+var actionColours = [sim.ActionSlots]string{"#f2c94c", "#d63a2f", "#3ca55c", "#3aa8c1", "#8c8c8c"}
+
+var actionCodes = [sim.ActionSlots]string{"S", "H", "D", "P", "R"}
+
+// evCell - one entry of an EV table
+type evCell struct {
+	Text    string
+	CI      string
+	Code    string
+	Colour  string
+	Reached bool
+}
+
+type evRow struct {
+	Hand  string
+	Cells []evCell
+}
+
+type categorySection struct {
+	Name     string
+	Summary  CategorySummary
+	Heatmap  template.HTML
+	EVTable  []evRow
+	Strategy string // action shares e.g. "Stand 43.1%, Hit 40.6%"
+}
+
+type pageData struct {
+	Options
+	Generated string
+	Meta      sim.Metadata
+	Summary   Summary
+	Ups       []string
+
+	Categories   []categorySection
+	ActionChart  template.HTML
+	ActionShares string
+	UpChart      template.HTML
+	Best, Worst  []Situation
+}
+
+// Write renders the report for a dataset as one HTML file (styles and charts inline)
+func Write(w io.Writer, sdm sim.SimDataMap, opts Options) error {
+	sum := Summarise(sdm)
+	page := pageData{
+		Options:   opts,
+		Generated: time.Now().Format("2006-01-02 15:04:05"),
+		Meta:      sum.Meta,
+		Summary:   sum,
+		Best:      sum.Best(opts.Top),
+		Worst:     sum.Worst(opts.Top),
+	}
+	ups := []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 1}
+	for _, up := range ups {
+		page.Ups = append(page.Ups, sim.UpLabel(up))
+	}
+
+	// per category: heatmap, EV table with confidence intervals
+	for category, cs := range sum.Categories {
+		grid := heatmap.FromDataset(sdm, category)
+		var svg strings.Builder
+		if err := grid.WriteSVG(&svg, heatmap.KindStrategy); err != nil {
+			return err
+		}
+		sec := categorySection{
+			Name:     sim.CategoryNames[category],
+			Summary:  cs,
+			Heatmap:  template.HTML(svg.String()),
+			Strategy: actionShares(cs.Actions),
+		}
+		for _, player := range grid.Rows {
+			row := evRow{Hand: sim.HandLabel(player, category)}
+			for _, up := range ups {
+				row.Cells = append(row.Cells, tableCell(sdm.Cell(sim.Context{}, up, player, category)))
+			}
+			sec.EVTable = append(sec.EVTable, row)
+		}
+		page.Categories = append(page.Categories, sec)
+	}
+
+	// action distribution over all states
+	var bars []bar
+	for action, n := range sum.Actions {
+		if n == 0 && action == sim.ActionSurrender {
+			continue
+		}
+		bars = append(bars, bar{
+			label:  sim.ActionNames[action],
+			value:  float64(n),
+			text:   fmt.Sprint(n),
+			colour: actionColours[action],
+		})
+	}
+	page.ActionChart = barChart(bars, 220)
+	page.ActionShares = actionShares(sum.Actions)
+
+	// average best EV by dealer upcard
+	bars = nil
+	for _, up := range ups {
+		ev := sum.UpAvgEV[up]
+		colour := "#1a9850"
+		if ev < 0 {
+			colour = "#d73027"
+		}
+		bars = append(bars, bar{label: sim.UpLabel(up), value: ev, text: fmt.Sprintf("%+.2f", ev), colour: colour})
+	}
+	page.UpChart = barChart(bars, 260)
+
+	return reportTemplate.Execute(w, page)
+}
+
+// tableCell formats the best action of a cell with its EV and 95% interval
+func tableCell(cell *sim.Cell) evCell {
+	if cell == nil {
+		return evCell{}
+	}
+	action, ok := cell.Best()
+	if !ok {
+		return evCell{}
+	}
+	sd := cell.Data[action]
+	c := evCell{
+		Text:    fmt.Sprintf("%+.3f", sd.ExpectedValue),
+		Code:    actionCodes[action],
+		Colour:  actionColours[action],
+		Reached: true,
+	}
+	if se, ok := sd.StdErr(); ok {
+		c.CI = fmt.Sprintf("±%.3f", 1.96*se)
+	}
+	return c
+}
+
+// actionShares lists the actions by share of states e.g. "Stand 43.1%, Hit 40.6%"
+func actionShares(counts ActionCounts) string {
+	total := counts.Total()
+	if total == 0 {
+		return "no states reached"
+	}
+	type share struct {
+		action, n int
+	}
+	var shares []share
+	for action, n := range counts {
+		if n > 0 {
+			shares = append(shares, share{action, n})
+		}
+	}
+	// largest first (insertion sort - at most five entries)
+	for i := 1; i < len(shares); i++ {
+		for j := i; j > 0 && shares[j].n > shares[j-1].n; j-- {
+			shares[j], shares[j-1] = shares[j-1], shares[j]
+		}
+	}
+	parts := make([]string, len(shares))
+	for i, s := range shares {
+		parts[i] = fmt.Sprintf("%s %.1f%%", sim.ActionNames[s.action], float64(s.n)/float64(total)*100)
+	}
+	return strings.Join(parts, ", ")
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ev": func(v float64) string { return fmt.Sprintf("%+.3f", v) },
+	"ci": func(s Situation) string {
+		if !s.HasCI {
+			return "-"
+		}
+		return fmt.Sprintf("[%+.3f, %+.3f]", s.CI95[0], s.CI95[1])
+	},
+	"action": func(a int) string { return sim.ActionNames[a] },
+	"up":     sim.UpLabel,
+	"dur":    func(d time.Duration) string { return d.Round(time.Second).String() },
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+}).Parse(reportHTML))
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 72em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 0.2em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; }
+th, td { border: 1px solid #ddd; padding: 0.25em 0.5em; text-align: right; }
+th { background: #f4f4f4; }
+td.hand, th.hand { text-align: left; }
+td.cell { font-family: monospace; font-size: 0.85em; line-height: 1.2; }
+td.cell small { color: #666; }
+td.cell .code { display: inline-block; width: 1.4em; text-align: center; font-weight: bold; }
+td.unreached { background: #eee; }
+.meta td { text-align: left; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="muted">Generated {{.Generated}} from {{.DataFile}}</p>
+
+<h2>Run</h2>
+<table class="meta">
+{{- with .Meta}}
+{{- if .Known}}
+<tr><th>Source</th><td>{{.Source}}</td></tr>
+<tr><th>Rules</th><td>{{.Rules}}{{if .InfiniteDeck}} (infinite deck){{end}}</td></tr>
+<tr><th>Hands simulated</th><td>{{.Hands}}</td></tr>
+<tr><th>Seed</th><td>{{if .Seed}}{{.Seed}}{{else}}-{{end}}</td></tr>
+<tr><th>Started</th><td>{{time .Started}}</td></tr>
+<tr><th>Finished</th><td>{{time .Finished}}</td></tr>
+<tr><th>Wall time</th><td>{{dur .WallTime}}</td></tr>
+<tr><th>Engine</th><td>{{.EngineVersion}}{{if .GitCommit}} @ {{.GitCommit}}{{end}}</td></tr>
+{{- else}}
+<tr><td>No metadata recorded (dataset saved before metadata was kept)</td></tr>
+{{- end}}
+{{- end}}
+</table>
+
+<h2>Overall</h2>
+<table class="meta">
+<tr><th>Game states analysed</th><td>{{.Summary.States}}</td></tr>
+<tr><th>Average EV of the best action</th><td>{{ev .Summary.AvgEV}}</td></tr>
+<tr><th>Best actions</th><td>{{.ActionShares}}</td></tr>
+{{- if .Best}}
+{{- with index .Best 0}}
+<tr><th>Best situation</th><td>{{.Hand}} vs {{up .Dealer}}: {{action .Action}} ({{ev .EV}})</td></tr>
+{{- end}}
+{{- with index .Worst 0}}
+<tr><th>Worst situation</th><td>{{.Hand}} vs {{up .Dealer}}: {{action .Action}} ({{ev .EV}})</td></tr>
+{{- end}}
+{{- end}}
+</table>
+
+<h3>Action distribution (states per best action)</h3>
+{{.ActionChart}}
+
+<h3>Average EV by dealer upcard</h3>
+{{.UpChart}}
+
+{{- range .Categories}}
+<h2>{{.Name}} hands</h2>
+<p>{{.Summary.States}} states, average EV {{ev .Summary.AvgEV}}. Strategy: {{.Strategy}}.</p>
+{{.Heatmap}}
+<h3>EV of the best action (95% CI half width)</h3>
+<table>
+<tr><th class="hand">Hand</th>{{range $.Ups}}<th>{{.}}</th>{{end}}</tr>
+{{- range .EVTable}}
+<tr><td class="hand">{{.Hand}}</td>
+{{- range .Cells}}
+{{- if .Reached}}<td class="cell"><span class="code" style="background: {{.Colour}}">{{.Code}}</span> {{.Text}}{{if .CI}}<br><small>{{.CI}}</small>{{end}}</td>
+{{- else}}<td class="unreached"></td>{{end}}
+{{- end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+
+<h2>Best situations</h2>
+<table>
+<tr><th class="hand">Hand</th><th>Dealer</th><th>Action</th><th>EV</th><th>95% CI</th><th>Trials</th></tr>
+{{- range .Best}}
+<tr><td class="hand">{{.Hand}}</td><td>{{up .Dealer}}</td><td>{{action .Action}}</td><td>{{ev .EV}}</td><td>{{ci .}}</td><td>{{.Trials}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Worst situations</h2>
+<table>
+<tr><th class="hand">Hand</th><th>Dealer</th><th>Action</th><th>EV</th><th>95% CI</th><th>Trials</th></tr>
+{{- range .Worst}}
+<tr><td class="hand">{{.Hand}}</td><td>{{up .Dealer}}</td><td>{{action .Action}}</td><td>{{ev .EV}}</td><td>{{ci .}}</td><td>{{.Trials}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"blackjack/sim"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	var sb strings.Builder
+	if err := Write(&sb, testDataset(), Options{Title: "Test report", DataFile: "data.json", Top: 3}); err != nil {
+		t.Fatal(err)
+	}
+	page := sb.String()
+	for _, part := range []string{
+		"<title>Test report</title>",
+		"from data.json",
+		"<tr><th>Game states analysed</th><td>5</td></tr>",
+		"<tr><th>Best situation</th><td>A,T (split) vs 6: Stand (&#43;0.700)</td></tr>",
+		"<tr><th>Worst situation</th><td>hard 16 (later) vs T: Stand (-0.540)</td></tr>",
+		"<h2>Hard hands</h2>", "<h2>Soft hands</h2>", "<h2>Pair hands</h2>",
+		`<td class="hand">8,8</td>`,
+		"<svg",
+	} {
+		if !strings.Contains(page, part) {
+			t.Errorf("report has no %q", part)
+		}
+	}
+	// the 11, 16 and 8,8 EV table rows, then three in each situation table
+	if n := strings.Count(page, `<tr><td class="hand">`); n != 9 {
+		t.Errorf("%d hand rows, want 9", n)
+	}
+}
+
+func TestActionShares(t *testing.T) {
+	tests := []struct {
+		counts ActionCounts
+		want   string
+	}{
+		{ActionCounts{}, "no states reached"},
+		{ActionCounts{sim.ActionStand: 1, sim.ActionHit: 3}, "Hit 75.0%, Stand 25.0%"},
+		{ActionCounts{sim.ActionDouble: 2}, "Double 100.0%"},
+	}
+	for _, tt := range tests {
+		if got := actionShares(tt.counts); got != tt.want {
+			t.Errorf("actionShares(%v) = %q, want %q", tt.counts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Simulation report
+
+Summarises a dataset the way the README's "Key Findings" section does - every
+reached (context, dealer up, player key, category) state counts once with the EV of its
+best action - and renders it with the strategy heatmaps and charts as a single
+self-contained HTML file.
+*/
+package report
+
+import (
+	"blackjack/sim"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Situation - one reached state and its best action
+type Situation struct {
+	Context  sim.Context
+	Dealer   int
+	Player   int
+	Category int
+	Action   int
+	EV       float64
+	StdErr   float64
+	CI95     [2]float64
+	HasCI    bool
+	Trials   int
+}
+
+// Hand names the player hand e.g. "A,6", "soft 17 (later)" for a later decision
+// or "T,T (split)" after a split
+func (s Situation) Hand() string {
+	if s.Context.Later {
+		return fmt.Sprintf("%s %d (%s)", strings.ToLower(sim.CategoryNames[s.Category]), s.Player, s.Context)
+	}
+	if s.Context.Split {
+		return sim.HandLabel(s.Player, s.Category) + " (split)"
+	}
+	return sim.HandLabel(s.Player, s.Category)
+}
+
+// ActionCounts - number of states per best action
+type ActionCounts [sim.ActionSlots]int
+
+// Total number of states counted
+func (a ActionCounts) Total() int {
+	total := 0
+	for _, n := range a {
+		total += n
+	}
+	return total
+}
+
+// CategorySummary - states, average EV and best actions of one hand category
+type CategorySummary struct {
+	Category int
+	States   int
+	AvgEV    float64
+	Actions  ActionCounts
+}
+
+// Summary - headline numbers of a dataset
+type Summary struct {
+	Meta sim.Metadata
+
+	States  int
+	AvgEV   float64
+	Actions ActionCounts
+
+	Categories [sim.CategorySlots]CategorySummary
+	UpAvgEV    [sim.DealerSlots]float64 // average best EV per dealer upcard
+	UpStates   [sim.DealerSlots]int
+
+	Situations []Situation // every reached state, best EV first
+}
+
+// Summarise collects the summary of a dataset
+func Summarise(sdm sim.SimDataMap) Summary {
+	s := Summary{Meta: *sdm.Metadata()}
+	for category := range s.Categories {
+		s.Categories[category].Category = category
+	}
+
+	sdm.EachCell(func(ctx sim.Context, dealer, player, category int, cell *sim.Cell) {
+		action, ok := cell.Best()
+		if !ok {
+			return
+		}
+		sd := cell.Data[action]
+		sit := Situation{
+			Context:  ctx,
+			Dealer:   dealer,
+			Player:   player,
+			Category: category,
+			Action:   action,
+			EV:       sd.ExpectedValue,
+			Trials:   sd.Trials,
+		}
+		sit.StdErr, sit.HasCI = sd.StdErr()
+		sit.CI95, _ = sd.CI95()
+		s.Situations = append(s.Situations, sit)
+
+		s.States++
+		s.AvgEV += sit.EV
+		s.Actions[action]++
+
+		cs := &s.Categories[category]
+		cs.States++
+		cs.AvgEV += sit.EV
+		cs.Actions[action]++
+
+		s.UpAvgEV[dealer] += sit.EV
+		s.UpStates[dealer]++
+	})
+
+	if s.States > 0 {
+		s.AvgEV /= float64(s.States)
+	}
+	for i := range s.Categories {
+		if s.Categories[i].States > 0 {
+			s.Categories[i].AvgEV /= float64(s.Categories[i].States)
+		}
+	}
+	for up := range s.UpAvgEV {
+		if s.UpStates[up] > 0 {
+			s.UpAvgEV[up] /= float64(s.UpStates[up])
+		}
+	}
+
+	sort.SliceStable(s.Situations, func(i, j int) bool {
+		return s.Situations[i].EV > s.Situations[j].EV
+	})
+	return s
+}
+
+// Best returns up to n states with the highest EV
+func (s Summary) Best(n int) []Situation {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(s.Situations) {
+		n = len(s.Situations)
+	}
+	return s.Situations[:n]
+}
+
+// Worst returns up to n states with the lowest EV, worst first
+func (s Summary) Worst(n int) []Situation {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(s.Situations) {
+		n = len(s.Situations)
+	}
+	worst := make([]Situation, n)
+	for i := range worst {
+		worst[i] = s.Situations[len(s.Situations)-1-i]
+	}
+	return worst
+}
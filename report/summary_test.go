@@ -0,0 +1,71 @@
+package report
+
+import (
+	"blackjack/sim"
+	"math"
+	"testing"
+)
+
+// testDataset reaches five states:
+//
+//	11 v 6 double +0.6, 16 v T hit -0.53, 8,8 v T split -0.4 (first decision)
+//	hard 16 v T stand -0.54 (later decision), A,T v 6 stand +0.7 (after a split)
+func testDataset() sim.SimDataMap {
+	sdm := sim.CreateSimDataStructure()
+	set := func(ctx sim.Context, up, player, category int, evs map[int]float64) {
+		for action, ev := range evs {
+			sdm.Set(sim.Key{Context: ctx, Dealer: up, Player: player, Category: category, Action: action},
+				sim.SimData{ExpectedValue: ev, Trials: 100, VarTrials: 100, M2: 99})
+		}
+	}
+	first, later, split := sim.Context{}, sim.Context{Later: true}, sim.Context{Split: true}
+	set(first, 6, 11, 0, map[int]float64{sim.ActionStand: -0.1, sim.ActionHit: 0.3, sim.ActionDouble: 0.6})
+	set(first, 10, 16, 0, map[int]float64{sim.ActionStand: -0.54, sim.ActionHit: -0.53})
+	set(first, 10, 8, 2, map[int]float64{sim.ActionStand: -0.5, sim.ActionSplit: -0.4})
+	set(later, 10, 16, 0, map[int]float64{sim.ActionStand: -0.54})
+	set(split, 6, 21, 1, map[int]float64{sim.ActionStand: 0.7})
+	return sdm
+}
+
+func TestSummarise(t *testing.T) {
+	s := Summarise(testDataset())
+	if s.States != 5 || s.Actions.Total() != 5 || math.Abs(s.AvgEV-(-0.034)) > 1e-12 {
+		t.Errorf("%d states (%d counted), average EV %g", s.States, s.Actions.Total(), s.AvgEV)
+	}
+	want := ActionCounts{sim.ActionStand: 2, sim.ActionHit: 1, sim.ActionDouble: 1, sim.ActionSplit: 1}
+	if s.Actions != want {
+		t.Errorf("best actions %v, want %v", s.Actions, want)
+	}
+	if hard := s.Categories[0]; hard.States != 3 || hard.Actions[sim.ActionStand] != 1 {
+		t.Errorf("hard hands %+v", hard)
+	}
+	if s.UpStates[10] != 3 || math.Abs(s.UpAvgEV[10]-(-0.49)) > 1e-12 || s.UpStates[2] != 0 {
+		t.Errorf("upcard T: %d states, average EV %g", s.UpStates[10], s.UpAvgEV[10])
+	}
+	for i := 1; i < len(s.Situations); i++ {
+		if s.Situations[i].EV > s.Situations[i-1].EV {
+			t.Fatalf("situations not sorted by EV: %+v", s.Situations)
+		}
+	}
+	if sit := s.Situations[0]; !sit.HasCI || sit.CI95[0] >= sit.EV || sit.CI95[1] <= sit.EV {
+		t.Errorf("best situation has no confidence interval: %+v", sit)
+	}
+}
+
+func TestBestWorst(t *testing.T) {
+	s := Summarise(testDataset())
+	best := s.Best(2)
+	if len(best) != 2 || best[0].Hand() != "A,T (split)" || best[1].Hand() != "11" {
+		t.Errorf("best %+v", best)
+	}
+	worst := s.Worst(2)
+	if len(worst) != 2 || worst[0].Hand() != "hard 16 (later)" || worst[1].Hand() != "16" {
+		t.Errorf("worst %+v", worst)
+	}
+	if len(s.Best(-1)) != 0 || len(s.Worst(10)) != 5 {
+		t.Errorf("out of range counts are not clamped")
+	}
+	if got := Summarise(sim.CreateSimDataStructure()); got.States != 0 || got.AvgEV != 0 || len(got.Best(3)) != 0 {
+		t.Errorf("empty dataset: %+v", got)
+	}
+}
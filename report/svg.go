@@ -0,0 +1,70 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// bar - one bar of a chart
+type bar struct {
+	label  string
+	value  float64
+	text   string // value label drawn next to the bar
+	colour string
+}
+
+// barChart renders vertical bars as inline SVG. Negative values hang below the
+// zero line.
+func barChart(bars []bar, height int) template.HTML {
+	const (
+		barW    = 44
+		gap     = 12
+		marginL = 16
+		marginT = 22 // room for value labels
+		marginB = 40 // room for bar labels (and labels of negative bars)
+	)
+	maxV, minV := 0.0, 0.0
+	for _, b := range bars {
+		if b.value > maxV {
+			maxV = b.value
+		}
+		if b.value < minV {
+			minV = b.value
+		}
+	}
+	span := maxV - minV
+	if span == 0 {
+		span = 1
+	}
+	plotH := float64(height - marginT - marginB)
+	zeroY := float64(marginT) + plotH*maxV/span
+	width := marginL*2 + len(bars)*(barW+gap) - gap
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`,
+		width, height, width, height)
+	for i, b := range bars {
+		x := marginL + i*(barW+gap)
+		h := plotH * b.value / span
+		y := zeroY - h
+		textY := y - 5
+		if h < 0 {
+			y, h = zeroY, -h
+			textY = zeroY + h + 13
+		}
+		fmt.Fprintf(&sb, `<rect x="%d" y="%.1f" width="%d" height="%.1f" fill="%s"/>`, x, y, barW, h, b.colour)
+		fmt.Fprintf(&sb, `<text x="%d" y="%.1f" text-anchor="middle">%s</text>`, x+barW/2, textY, escape(b.text))
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, x+barW/2, height-8, escape(b.label))
+	}
+	fmt.Fprintf(&sb, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#444"/>`, marginL-4, zeroY, width-marginL+4, zeroY)
+	sb.WriteString("</svg>")
+	return template.HTML(sb.String())
+}
+
+func escape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,403 @@
+package sim
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+/*
+Binary dataset format (little endian)
+
+	magic     "BJSD"
+	version   uint16
+	dims      uint8 x5 - context rows (ContextSlots per true count bucket), DealerSlots,
+	          PlayerSlots, CategorySlots, ActionSlots
+	metadata  uint32 length + JSON encoded Metadata (version 2+)
+	records   uint32
+	record    context, dealer, player, category, action uint8
+	          ExpectedValue float64, Trials uvarint, M2 float64, VarTrials uvarint
+	pairs     uint32 (version 3+)
+	pair      context, dealer, player, category, PairIndex uint8 + the record
+	          fields of the paired difference
+	insurance uint32 (version 5+)
+	record    true count bucket uint8 (from 0 for -CountRange) + the record fields
+	checksum  uint32 - CRC-32 (IEEE) of everything before it
+
+The context byte is the table row: bucket * ContextSlots + decision context.
+
+Versions before 4 hold legacy keys: no context dimension or byte (see fromLegacy).
+Version 4 files written before the post-split contexts existed have 2 contexts.
+Only records in the layout are written, so the layout survives a round trip.
+Either format may be gzip compressed as a whole (".gz" file names).
+*/
+
+const (
+	binaryMagic   = "BJSD"
+	binaryVersion = 5 // 1: no metadata block, 2: no paired differences, 3: legacy keys, 4: no count
+)
+
+// DatasetFormat - file format of a saved dataset
+type DatasetFormat int
+
+const (
+	FormatJSON DatasetFormat = iota
+	FormatBinary
+)
+
+func (f DatasetFormat) String() string {
+	if f == FormatBinary {
+		return "binary"
+	}
+	return "json"
+}
+
+// FormatFor picks the format from a file name: .bjsd is binary, anything else JSON.
+// A trailing .gz adds gzip compression (e.g. data.bjsd.gz, data.json.gz).
+func FormatFor(filename string) (format DatasetFormat, compressed bool) {
+	name := strings.ToLower(filename)
+	if strings.HasSuffix(name, ".gz") {
+		compressed = true
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	if strings.HasSuffix(name, ".bjsd") {
+		return FormatBinary, compressed
+	}
+	return FormatJSON, compressed
+}
+
+// MarshalBinary encodes the dataset in the binary format
+func (sdm SimDataMap) MarshalBinary() ([]byte, error) {
+	if sdm.t == nil {
+		return nil, errors.New("no dataset")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(binaryMagic)
+	binary.Write(&buf, binary.LittleEndian, uint16(binaryVersion))
+	buf.Write([]byte{byte(len(sdm.t.cells)), DealerSlots, PlayerSlots, CategorySlots, ActionSlots})
+
+	meta, err := json.Marshal(sdm.t.meta)
+	if err != nil {
+		return nil, err
+	}
+	binary.Write(&buf, binary.LittleEndian, uint32(len(meta)))
+	buf.Write(meta)
+
+	count := 0
+	sdm.Each(func(Key, SimData) { count++ })
+	binary.Write(&buf, binary.LittleEndian, uint32(count))
+
+	sdm.Each(func(k Key, sd SimData) {
+		buf.Write([]byte{byte(sdm.t.row(k.Context)), byte(k.Dealer), byte(k.Player), byte(k.Category), byte(k.Action)})
+		putSimData(&buf, sd)
+	})
+
+	// paired differences with trials
+	pairs := 0
+	sdm.EachCell(func(_ Context, _, _, _ int, c *Cell) {
+		for _, diff := range c.Diffs {
+			if diff.Trials > 0 {
+				pairs++
+			}
+		}
+	})
+	binary.Write(&buf, binary.LittleEndian, uint32(pairs))
+	sdm.EachCell(func(ctx Context, i, j, k int, c *Cell) {
+		for p, diff := range c.Diffs {
+			if diff.Trials > 0 {
+				buf.Write([]byte{byte(sdm.t.row(ctx)), byte(i), byte(j), byte(k), byte(p)})
+				putSimData(&buf, diff)
+			}
+		}
+	})
+
+	insured := 0
+	for _, sd := range sdm.t.insurance {
+		if sd.Trials > 0 {
+			insured++
+		}
+	}
+	binary.Write(&buf, binary.LittleEndian, uint32(insured))
+	for b, sd := range sdm.t.insurance {
+		if sd.Trials > 0 {
+			buf.WriteByte(byte(b))
+			putSimData(&buf, sd)
+		}
+	}
+
+	binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a dataset in the binary format, checking the checksum
+// and that the schema matches this build
+func (sdm *SimDataMap) UnmarshalBinary(data []byte) error {
+	const headerLen = len(binaryMagic) + 2 + 4 + 4
+	if len(data) < headerLen+4 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return errors.New("not a binary dataset")
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return errors.New("binary dataset checksum mismatch (file corrupt or truncated)")
+	}
+
+	r := bytes.NewReader(body[len(binaryMagic):])
+	var version uint16
+	binary.Read(r, binary.LittleEndian, &version)
+	if version < 1 || version > binaryVersion {
+		return fmt.Errorf("binary dataset version %d is not supported (want 1 to %d)", version, binaryVersion)
+	}
+	legacy := version < 4
+
+	// legacy tables have no context dimension and a smaller player dimension
+	want := []uint8{ContextSlots, DealerSlots, PlayerSlots, CategorySlots, ActionSlots}
+	if legacy {
+		want = []uint8{DealerSlots, 21, CategorySlots, ActionSlots}
+	}
+	dims := make([]uint8, len(want))
+	r.Read(dims)
+	// contexts keep their index as new ones are added, so fewer of them still fit;
+	// counted tables repeat them per true count bucket
+	countRange := 0
+	switch {
+	case legacy:
+	case dims[0] <= ContextSlots:
+		want[0] = dims[0]
+	case dims[0]%ContextSlots == 0 && dims[0]/ContextSlots%2 == 1:
+		want[0] = dims[0]
+		countRange = int(dims[0]) / ContextSlots / 2
+	}
+	if !bytes.Equal(dims, want) {
+		return fmt.Errorf("binary dataset table %v does not match %v", dims, want)
+	}
+
+	var meta Metadata
+	if version >= 2 {
+		var metaLen uint32
+		binary.Read(r, binary.LittleEndian, &metaLen)
+		if int(metaLen) > r.Len() {
+			return errors.New("binary dataset metadata is truncated")
+		}
+		data := make([]byte, metaLen)
+		r.Read(data)
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return fmt.Errorf("binary dataset metadata: %w", err)
+		}
+	}
+
+	out := SimDataMap{t: newSimTable(countRange)}
+	out.t.meta = meta
+
+	// keys are read with a zero context byte in front for legacy versions
+	keyLen := 5
+	if legacy {
+		keyLen = 4
+	}
+	readKey := func() ([5]int, error) {
+		var key [5]uint8
+		if _, err := io.ReadFull(r, key[5-keyLen:]); err != nil {
+			return [5]int{}, err
+		}
+		if int(key[0]) >= len(out.t.cells) {
+			return [5]int{}, fmt.Errorf("context %d is outside of the table", key[0])
+		}
+		return [5]int{int(key[0]), int(key[1]), int(key[2]), int(key[3]), int(key[4])}, nil
+	}
+
+	var records []legacyRecord
+	var pairs []legacyPair
+
+	var count uint32
+	binary.Read(r, binary.LittleEndian, &count)
+	for i := uint32(0); i < count; i++ {
+		key, err := readKey()
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		sd, err := readSimData(r)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if legacy {
+			records = append(records, legacyRecord{key[1], key[2], key[3], key[4], sd})
+			continue
+		}
+		if !out.Set(Key{out.t.context(key[0]), key[1], key[2], key[3], key[4]}, sd) {
+			return fmt.Errorf("record %d: key %v is outside of the table", i, key)
+		}
+	}
+
+	if version >= 3 {
+		binary.Read(r, binary.LittleEndian, &count)
+		for i := uint32(0); i < count; i++ {
+			key, err := readKey()
+			if err != nil {
+				return fmt.Errorf("pair %d: %w", i, err)
+			}
+			diff, err := readSimData(r)
+			if err != nil {
+				return fmt.Errorf("pair %d: %w", i, err)
+			}
+			if legacy {
+				pairs = append(pairs, legacyPair{key[1], key[2], key[3], key[4], diff})
+				continue
+			}
+			cell := out.Cell(out.t.context(key[0]), key[1], key[2], key[3])
+			if cell == nil || key[4] >= PairSlots {
+				return fmt.Errorf("pair %d: key %v is outside of the layout", i, key)
+			}
+			cell.Diffs[key[4]] = diff
+		}
+	}
+	if version >= 5 {
+		binary.Read(r, binary.LittleEndian, &count)
+		for i := uint32(0); i < count; i++ {
+			b, err := r.ReadByte()
+			if err == nil && int(b) >= len(out.t.insurance) {
+				err = fmt.Errorf("true count bucket %d is outside of the table", b)
+			}
+			if err != nil {
+				return fmt.Errorf("insurance %d: %w", i, err)
+			}
+			if out.t.insurance[b], err = readSimData(r); err != nil {
+				return fmt.Errorf("insurance %d: %w", i, err)
+			}
+		}
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("binary dataset has %d trailing bytes", r.Len())
+	}
+
+	if legacy {
+		var err error
+		if out, err = fromLegacy(meta, records, pairs); err != nil {
+			return err
+		}
+	}
+	*sdm = out
+	return nil
+}
+
+// putSimData appends the fields of a record
+func putSimData(buf *bytes.Buffer, sd SimData) {
+	var scratch [binary.MaxVarintLen64]byte
+	putFloat := func(v float64) {
+		binary.LittleEndian.PutUint64(scratch[:8], math.Float64bits(v))
+		buf.Write(scratch[:8])
+	}
+	putCount := func(n int) {
+		buf.Write(scratch[:binary.PutUvarint(scratch[:], uint64(n))])
+	}
+	putFloat(sd.ExpectedValue)
+	putCount(sd.Trials)
+	putFloat(sd.M2)
+	putCount(sd.VarTrials)
+}
+
+// readSimData reads the fields of a record
+func readSimData(r *bytes.Reader) (SimData, error) {
+	var sd SimData
+	var bits uint64
+	if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+		return sd, err
+	}
+	sd.ExpectedValue = math.Float64frombits(bits)
+	trials, err := binary.ReadUvarint(r)
+	if err != nil {
+		return sd, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+		return sd, err
+	}
+	sd.M2 = math.Float64frombits(bits)
+	varTrials, err := binary.ReadUvarint(r)
+	if err != nil {
+		return sd, err
+	}
+	sd.Trials, sd.VarTrials = int(trials), int(varTrials)
+	return sd, nil
+}
+
+// Encode returns the dataset in the given format
+func (sdm SimDataMap) Encode(format DatasetFormat, compressed bool) ([]byte, error) {
+	var data []byte
+	var err error
+	if format == FormatBinary {
+		data, err = sdm.MarshalBinary()
+	} else {
+		data, err = json.Marshal(sdm)
+	}
+	if err != nil || !compressed {
+		return data, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile writes the dataset in the format its name selects (see FormatFor),
+// via a temp file and rename
+func (sdm SimDataMap) WriteFile(filename string) error {
+	format, compressed := FormatFor(filename)
+	data, err := sdm.Encode(format, compressed)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// DecodeDataset reads a dataset in any supported format, detected from its content
+func DecodeDataset(data []byte) (SimDataMap, DatasetFormat, error) {
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return SimDataMap{}, FormatJSON, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return SimDataMap{}, FormatJSON, err
+		}
+	}
+
+	var sdm SimDataMap
+	if bytes.HasPrefix(data, []byte(binaryMagic)) {
+		err := sdm.UnmarshalBinary(data)
+		return sdm, FormatBinary, err
+	}
+	if err := json.Unmarshal(data, &sdm); err != nil {
+		return SimDataMap{}, FormatJSON, err
+	}
+	if sdm.IsZero() {
+		return SimDataMap{}, FormatJSON, errors.New("no dataset")
+	}
+	return sdm, FormatJSON, nil
+}
+
+// LoadDataset reads a dataset file, detecting the format (binary or JSON,
+// optionally gzip compressed)
+func LoadDataset(filename string) (SimDataMap, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return SimDataMap{}, err
+	}
+	sdm, _, err := DecodeDataset(data)
+	if err != nil {
+		return SimDataMap{}, fmt.Errorf("%s: %w", filename, err)
+	}
+	return sdm, nil
+}
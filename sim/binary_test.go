@@ -0,0 +1,112 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// testDataset returns a dataset with random trials in every record of its
+// layout, paired differences and insurance records
+func testDataset(countRange int, seed int64) SimDataMap {
+	rng := rand.New(rand.NewSource(seed))
+	sdm := CreateCountedStructure(countRange)
+	meta := sdm.Metadata()
+	*meta = SimulationMetadata(false)
+	meta.Seed, meta.Hands = seed, 1000
+	if countRange > 0 {
+		meta.Count, meta.CountRange, meta.Penetration = "hilo", countRange, 0.75
+	}
+
+	sdm.EachCell(func(ctx Context, i, j, k int, c *Cell) {
+		for action := range c.Data {
+			if !c.Has(action) {
+				continue
+			}
+			for n := 1 + rng.Intn(3); n > 0; n-- {
+				c.Data[action].add(rng.NormFloat64())
+			}
+		}
+		c.Diffs[PairIndex(ActionStand, ActionHit)].add(rng.NormFloat64())
+	})
+	for b := -countRange; b <= countRange; b++ {
+		sdm.Insurance(b).add(rng.Float64())
+	}
+	return sdm
+}
+
+// sameRecords reports the first difference between the records of two datasets
+func sameRecords(t *testing.T, got, want SimDataMap) {
+	t.Helper()
+	if !got.SameLayout(want) {
+		t.Fatal("layouts differ")
+	}
+	if got.CountRange() != want.CountRange() {
+		t.Fatalf("count range %d, want %d", got.CountRange(), want.CountRange())
+	}
+	want.EachCell(func(ctx Context, i, j, k int, w *Cell) {
+		g := got.Cell(ctx, i, j, k)
+		if g.Data != w.Data || g.Diffs != w.Diffs {
+			t.Fatalf("cell %s/%d/%d/%d: %+v, want %+v", ctx, i, j, k, *g, *w)
+		}
+	})
+	for b := -want.CountRange(); b <= want.CountRange(); b++ {
+		if g, w := *got.Insurance(b), *want.Insurance(b); g != w {
+			t.Fatalf("insurance at %+d: %+v, want %+v", b, g, w)
+		}
+	}
+}
+
+func TestDatasetRoundTrip(t *testing.T) {
+	for _, countRange := range []int{0, 3} {
+		want := testDataset(countRange, 42)
+		for _, tt := range []struct {
+			format     DatasetFormat
+			compressed bool
+		}{
+			{FormatBinary, false},
+			{FormatBinary, true},
+			{FormatJSON, false},
+			{FormatJSON, true},
+		} {
+			data, err := want.Encode(tt.format, tt.compressed)
+			if err != nil {
+				t.Fatalf("%s (compressed %v): %v", tt.format, tt.compressed, err)
+			}
+			got, format, err := DecodeDataset(data)
+			if err != nil {
+				t.Fatalf("%s (compressed %v): %v", tt.format, tt.compressed, err)
+			}
+			if format != tt.format {
+				t.Errorf("%s (compressed %v): decoded as %s", tt.format, tt.compressed, format)
+			}
+			sameRecords(t, got, want)
+
+			g, w := got.Metadata(), want.Metadata()
+			if g.Source != w.Source || g.Rules != w.Rules || g.Seed != w.Seed || g.Hands != w.Hands ||
+				g.KeyVersion != w.KeyVersion || g.Count != w.Count || g.CountRange != w.CountRange || g.Penetration != w.Penetration {
+				t.Errorf("%s (compressed %v): metadata %+v, want %+v", tt.format, tt.compressed, *g, *w)
+			}
+		}
+	}
+}
+
+func TestDecodeDatasetErrors(t *testing.T) {
+	full, err := testDataset(0, 1).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"magic only", []byte(binaryMagic)},
+		{"truncated binary", full[:len(full)/2]},
+		{"truncated json", []byte(`{"Metadata":{`)},
+		{"gzip header only", []byte{0x1f, 0x8b}},
+	} {
+		if _, _, err := DecodeDataset(tt.data); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
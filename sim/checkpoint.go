@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"blackjack/counting"
+	"blackjack/game"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Checkpoint - everything needed to continue a simulation run where it stopped.
+// It is only written at the end of a batch, along with the dataset in OutFile
+// (a cancelled batch is dropped), so a resumed run goes on exactly as if it had
+// never stopped. DatasetHands and DatasetTrials tie it to that dataset save.
+type Checkpoint struct {
+	HandsCompleted int
+	HandsTarget    int
+
+	// run config
+	OutFile      string
+	Seed         int64
+	Workers      int
+	InfiniteDeck bool
+	Decks        int
+	Count        string
+	CountRange   int
+	Penetration  float64
+	Convergence  Convergence
+
+	RNGState []uint64    // generator state per worker
+	Shoes    []ShoeState // counted shoe per worker (none for uncounted runs)
+	Saved    time.Time
+
+	DatasetHands  int // hands and trials in OutFile when the checkpoint was saved
+	DatasetTrials int
+}
+
+// update records the progress of the run after a merged batch
+func (cp *Checkpoint) update(done int, workers []*simWorker, dataset SimDataMap) {
+	cp.HandsCompleted = done
+	cp.RNGState = make([]uint64, len(workers))
+	for i, w := range workers {
+		cp.RNGState[i] = w.src.State
+	}
+	cp.Shoes = nil
+	for _, w := range workers {
+		if w.shoe != nil && w.shoe.counted {
+			cp.Shoes = append(cp.Shoes, ShoeState{Cards: w.shoe.deck.Cards, Drawn: w.shoe.deck.Drawn, Count: w.shoe.count})
+		}
+	}
+	cp.Saved = time.Now()
+	cp.DatasetHands = dataset.Metadata().Hands
+	cp.DatasetTrials = dataset.TotalTrials()
+}
+
+// ShoeState - a counted shoe part way dealt: the shuffled cards, the cards dealt
+// and the count of them
+type ShoeState struct {
+	Cards []game.Card
+	Drawn int
+	Count counting.Counter
+}
+
+// Save writes the checkpoint to filename
+func (cp *Checkpoint) Save(filename string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// LoadCheckpoint reads a checkpoint file
+func LoadCheckpoint(filename string) (*Checkpoint, error) {
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(file, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
@@ -0,0 +1,76 @@
+package sim
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	dataset := newTestDataset(2)
+	workers := newWorkers(2, 3)
+	runBatch(context.Background(), workers, 30, dataset)
+	for _, w := range workers {
+		dataset.absorb(w.shard)
+	}
+	dataset.Metadata().Hands = 30
+
+	want := &Checkpoint{HandsTarget: 100, OutFile: "run.json", Seed: 3, Workers: 2, Decks: 2,
+		Count: "hilo", CountRange: 2, Penetration: 0.75}
+	want.update(30, workers, dataset)
+	if want.DatasetHands != 30 || want.DatasetTrials != dataset.TotalTrials() || len(want.Shoes) != 2 {
+		t.Fatalf("update: %d hands, %d trials, %d shoes", want.DatasetHands, want.DatasetTrials, len(want.Shoes))
+	}
+
+	filename := filepath.Join(t.TempDir(), "run.checkpoint")
+	if err := want.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HandsCompleted != 30 || got.OutFile != want.OutFile || got.DatasetTrials != want.DatasetTrials {
+		t.Errorf("loaded %+v", *got)
+	}
+	for i, w := range workers {
+		if got.RNGState[i] != w.src.State {
+			t.Errorf("worker %d: generator state %d, want %d", i, got.RNGState[i], w.src.State)
+		}
+		s := got.Shoes[i]
+		if s.Drawn != w.shoe.deck.Drawn || len(s.Cards) != len(w.shoe.deck.Cards) || s.Count.Running != w.shoe.count.Running {
+			t.Errorf("worker %d: shoe drawn %d of %d, running %g, want %d of %d, %g", i, s.Drawn, len(s.Cards),
+				s.Count.Running, w.shoe.deck.Drawn, len(w.shoe.deck.Cards), w.shoe.count.Running)
+		}
+	}
+}
+
+// a resume loads the output file and refuses one saved after the checkpoint
+func TestResumeChangedDataset(t *testing.T) {
+	dir := t.TempDir()
+	dataset := testDataset(0, 5)
+	out := filepath.Join(dir, "run.json")
+	if err := dataset.Save(out, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := &Checkpoint{HandsCompleted: 1000, HandsTarget: 2000, OutFile: out, Seed: 5, Workers: 1,
+		Decks: 1, RNGState: []uint64{1}, DatasetHands: 1000, DatasetTrials: dataset.TotalTrials() - 1}
+	filename := filepath.Join(dir, "run.checkpoint")
+	if err := cp.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	err := ResumeBJ(context.Background(), filename)
+	if err == nil || !strings.Contains(err.Error(), "changed") {
+		t.Errorf("resume over a changed dataset: %v", err)
+	}
+
+	cp.OutFile = filepath.Join(dir, "missing.json")
+	if err := cp.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResumeBJ(context.Background(), filename); err == nil {
+		t.Errorf("resume without its dataset: no error")
+	}
+}
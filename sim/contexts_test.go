@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"blackjack/game"
+	"testing"
+)
+
+func TestParseContext(t *testing.T) {
+	for i := 0; i < ContextSlots; i++ {
+		for _, count := range []int{0, 2, -3} {
+			want := contextAt(i, count)
+			got, err := ParseContext(want.String())
+			if err != nil || got != want {
+				t.Errorf("ParseContext(%q) = %+v, %v", want, got, err)
+			}
+		}
+	}
+	if got := (Context{Later: true, Split: true, Count: 2}).String(); got != "split-later tc+2" {
+		t.Errorf("split later context at tc +2 prints as %q", got)
+	}
+	for _, s := range []string{"", "second", "first tc", "first tc+x", "First"} {
+		if _, err := ParseContext(s); err == nil {
+			t.Errorf("ParseContext(%q): no error", s)
+		}
+	}
+}
+
+// once a hand is split every hand of the round is played in a split context
+func TestHandStateSplit(t *testing.T) {
+	// 8,8 v T; the split hands draw 3 and 9, then the first hand hits a 2
+	gs := game.StartRound(game.Deck{Cards: hand(8, 8, 10, 7, 3, 9, 2, 5, 5)})
+	check := func(step string, want Context, player, category int) {
+		t.Helper()
+		ctx, p, c := HandState(&gs)
+		if ctx != want || p != player || c != category {
+			t.Errorf("%s: %s %d/%d, want %s %d/%d", step, ctx, p, c, want, player, category)
+		}
+	}
+	check("dealt", Context{}, 8, 2)
+	gs.ActionCalc(0b100)
+	check("split", Context{Split: true}, 11, 0)
+	gs.ActionCalc(0b001)
+	check("hit", Context{Split: true, Later: true}, 13, 0)
+	gs.ActionCalc(0)
+	check("second hand", Context{Split: true}, 17, 0)
+}
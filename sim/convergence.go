@@ -0,0 +1,77 @@
+package sim
+
+import (
+	"math"
+)
+
+// minimum trials per action before a cell can count as resolved
+const minConvergenceTrials = 1_000
+
+// ----------------------------------------------------------------------------
+// Convergence criterion
+
+// Convergence decides when a run has sampled enough. A cell is resolved when the
+// gap between its best and second best action exceeds Confidence (two sided
+// normal interval), or when the standard error of both is below StdErr.
+// A zero value disables that test.
+type Convergence struct {
+	Confidence float64
+	StdErr     float64
+}
+
+func (c Convergence) Enabled() bool {
+	return c.Confidence > 0 || c.StdErr > 0
+}
+
+// z returns the two sided normal quantile for the confidence level
+func (c Convergence) z() float64 {
+	return math.Sqrt2 * math.Erfinv(c.Confidence)
+}
+
+// Unresolved counts the reachable cells (cells with data) that are not yet resolved
+func (c Convergence) Unresolved(dataset SimDataMap) (unresolved, reachable int) {
+	z := c.z()
+	dataset.EachCell(func(_ Context, _, _, _ int, cell *Cell) {
+		if !cell.Reached() {
+			return
+		}
+		reachable++
+		if !c.resolved(cell, z) {
+			unresolved++
+		}
+	})
+	return unresolved, reachable
+}
+
+func (c Convergence) resolved(cell *Cell, z float64) bool {
+	// best and second best action by EV
+	var best, second *SimData
+	for action := range cell.Data {
+		if !cell.Has(action) {
+			continue
+		}
+		sd := cell.Data[action]
+		if sd.VarTrials < minConvergenceTrials {
+			return false
+		}
+		if best == nil || sd.ExpectedValue > best.ExpectedValue {
+			best, second = &sd, best
+		} else if second == nil || sd.ExpectedValue > second.ExpectedValue {
+			second = &sd
+		}
+	}
+	if second == nil {
+		return true // single action - nothing to decide
+	}
+
+	seBest, _ := best.StdErr()
+	seSecond, _ := second.StdErr()
+	if c.StdErr > 0 && seBest <= c.StdErr && seSecond <= c.StdErr {
+		return true
+	}
+	gapSE := math.Sqrt(seBest*seBest + seSecond*seSecond)
+	if sig := cell.Significance(); sig.Paired {
+		gapSE = sig.StdErr // the shared deal cancels out of the paired difference
+	}
+	return c.Confidence > 0 && best.ExpectedValue-second.ExpectedValue > z*gapSE
+}
@@ -0,0 +1,59 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvergenceResolved(t *testing.T) {
+	tests := []struct {
+		name       string
+		stand, hit SimData
+		diff       SimData // paired value(stand) - value(hit), none if zero
+		conv       Convergence
+		resolved   bool
+	}{
+		{"clear gap", record(-0.2, 0.01, 2000), record(-0.5, 0.01, 2000), SimData{},
+			Convergence{Confidence: 0.95}, true},
+		{"close gap", record(-0.2, 0.01, 2000), record(-0.21, 0.01, 2000), SimData{},
+			Convergence{Confidence: 0.95}, false},
+		{"close gap, small standard errors", record(-0.2, 0.01, 2000), record(-0.21, 0.01, 2000), SimData{},
+			Convergence{Confidence: 0.95, StdErr: 0.02}, true},
+		{"standard error above the target", record(-0.2, 0.03, 2000), record(-0.21, 0.01, 2000), SimData{},
+			Convergence{StdErr: 0.02}, false},
+		{"paired differences resolve a close gap", record(-0.2, 0.01, 2000), record(-0.21, 0.01, 2000),
+			record(0.01, 0.001, 2000), Convergence{Confidence: 0.95}, true},
+		{"too few trials", record(-0.2, 0.01, minConvergenceTrials-1), record(-0.5, 0.01, 2000), SimData{},
+			Convergence{Confidence: 0.95}, false},
+	}
+	for _, tt := range tests {
+		c := Cell{Layout: 0b11}
+		c.Data[ActionStand], c.Data[ActionHit] = tt.stand, tt.hit
+		c.Diffs[PairIndex(ActionStand, ActionHit)] = tt.diff
+		if got := tt.conv.resolved(&c, tt.conv.z()); got != tt.resolved {
+			t.Errorf("%s: resolved %v, want %v", tt.name, got, tt.resolved)
+		}
+	}
+}
+
+func TestConvergenceUnresolved(t *testing.T) {
+	if (Convergence{}).Enabled() {
+		t.Errorf("zero Convergence is enabled")
+	}
+	conv := Convergence{Confidence: 0.95}
+	if z := conv.z(); math.Abs(z-1.959963984540054) > 1e-9 {
+		t.Errorf("z at 95%%: %g", z)
+	}
+
+	sdm := CreateSimDataStructure()
+	set := func(player int, stand, hit float64) {
+		sdm.Set(Key{Dealer: 10, Player: player, Action: ActionStand}, record(stand, 0.01, 2000))
+		sdm.Set(Key{Dealer: 10, Player: player, Action: ActionHit}, record(hit, 0.01, 2000))
+		sdm.Set(Key{Dealer: 10, Player: player, Action: ActionDouble}, record(-1, 0.02, 2000))
+	}
+	set(12, -0.22, -0.21) // closer than 1.96 standard errors: unresolved
+	set(20, 0.55, -0.85)
+	if unresolved, reachable := conv.Unresolved(sdm); unresolved != 1 || reachable != 2 {
+		t.Errorf("%d of %d cells unresolved, want 1 of 2", unresolved, reachable)
+	}
+}
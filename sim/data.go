@@ -1,17 +1,28 @@
 /*
 How to record the blackjack data for the simulation
 
-first layer key - dealer score
-second layer key - player score, record soft playerscores seperately for ace boolean (only for score <= 10)-
-third layer key - options [hit, stand, double down, split]
-values - [expected value, number of trials]
+first layer key - decision context: first decision on the hand (two cards) or later,
+	on an original hand or one that came from a split, and the true count bucket in
+	counted datasets
+second layer key - dealer score
+third layer key - player key: hard total, soft total or pair rank (see StateOf)
+fourth layer key - hand category [hard, soft, pair]
+fifth layer key - options [stand, hit, double down, split]
+values - [expected value, number of trials, variance accumulator]
+JSON also carries the derived standard error and 95% confidence interval of each value
+
+In memory the layers are a dense table indexed by the keys, the JSON file keeps
+the nested layout above.
 */
 package sim
 
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"sort"
+	"strconv"
 )
 
 // Complete structure for simulation data
@@ -21,104 +32,744 @@ type SimData struct {
 	// PlayerScores int // player score
 	// PlayerOptions int // 0: no ace, 1: has ace, 2: split
 	// ChosenAction int // 0: hit, 1: stand, 2: double down, 3: split
-	ExpectedValue float32 // resulting value of the action
+	ExpectedValue float64 // resulting value of the action (running mean)
 	Trials int // number of trials for this data set
+
+	// Welford variance accumulator - sum of squared deviations over VarTrials trials
+	// (VarTrials < Trials for datasets recorded before variance was tracked)
+	M2 float64
+	VarTrials int
+}
+
+// add records one trial (Welford online update)
+func (sd *SimData) add(value float64) {
+	sd.Trials++
+	delta := value - sd.ExpectedValue
+	sd.ExpectedValue += delta / float64(sd.Trials)
+	sd.M2 += delta * (value - sd.ExpectedValue)
+	sd.VarTrials++
+}
+
+// merge combines the trials of o into sd (Chan et al. parallel update)
+func (sd *SimData) merge(o SimData) {
+	if o.Trials == 0 {
+		return
+	}
+	trials := sd.Trials + o.Trials
+	delta := o.ExpectedValue - sd.ExpectedValue
+	sd.ExpectedValue += delta * float64(o.Trials) / float64(trials)
+	sd.Trials = trials
+
+	if varTrials := sd.VarTrials + o.VarTrials; varTrials > 0 {
+		sd.M2 += o.M2 + delta*delta*float64(sd.VarTrials)*float64(o.VarTrials)/float64(varTrials)
+		sd.VarTrials = varTrials
+	}
+}
+
+// Variance of a single trial, false if not enough trials carry variance
+func (sd SimData) Variance() (float64, bool) {
+	if sd.VarTrials < 2 {
+		return 0, false
+	}
+	return sd.M2 / float64(sd.VarTrials-1), true
+}
+
+// StdErr - standard error of ExpectedValue
+func (sd SimData) StdErr() (float64, bool) {
+	v, ok := sd.Variance()
+	if !ok {
+		return 0, false
+	}
+	return math.Sqrt(v / float64(sd.Trials)), true
+}
+
+// CI95 - 95% confidence interval of ExpectedValue
+func (sd SimData) CI95() ([2]float64, bool) {
+	se, ok := sd.StdErr()
+	if !ok {
+		return [2]float64{}, false
+	}
+	return [2]float64{sd.ExpectedValue - 1.96*se, sd.ExpectedValue + 1.96*se}, true
+}
+
+// MarshalJSON adds the standard error and confidence interval to the stored fields
+func (sd SimData) MarshalJSON() ([]byte, error) {
+	type stored SimData // no methods - avoids recursion
+	out := struct {
+		stored
+		StdError *float64   `json:",omitempty"`
+		CI95     *[2]float64 `json:",omitempty"`
+	}{stored: stored(sd)}
+
+	if se, ok := sd.StdErr(); ok {
+		ci, _ := sd.CI95()
+		out.StdError, out.CI95 = &se, &ci
+	}
+	return json.Marshal(out)
+}
+
+// ============================================================================
+// Dataset table
+
+// table dimensions - dataset keys index the table directly
+const (
+	DealerSlots   = 11 // dealer shown score 1-10
+	PlayerSlots   = 22 // hard total 4-21, soft total 12-21, pair rank 1-10
+	CategorySlots = 3  // 0: hard, 1: soft, 2: pair
+	ActionSlots   = 5  // ActionStand .. ActionSurrender
+	PairSlots     = ActionSlots * (ActionSlots - 1) / 2 // action pairs (see PairIndex)
+)
+
+// Key - position of one action record in the dataset
+type Key struct {
+	Context  Context
+	Dealer   int
+	Player   int
+	Category int
+	Action   int
+}
+
+// Cell - the action records of one context / dealer score / player key / category
+type Cell struct {
+	Data   [ActionSlots]SimData
+	Layout uint8 // bit per action that is part of the dataset layout
+
+	// paired differences value(a) - value(b) over the deals on which both actions
+	// were played (a < b, indexed by PairIndex)
+	Diffs [PairSlots]SimData
+}
+
+// PairIndex returns the Diffs index of the action pair a < b
+func PairIndex(a, b int) int {
+	// pairs ordered (0,1) (0,2) .. (0,4) (1,2) ..
+	return a*(2*ActionSlots-a-1)/2 + b - a - 1
 }
 
-// map of SimData dealer score, player score, player ace boolean, chosen action
-type SimDataMap map[int]map[int]map[int]map[int]SimData
+// Has reports whether action is part of the layout
+func (c *Cell) Has(action int) bool {
+	return c.Layout&(1<<action) != 0
+}
 
+// Reached reports whether any action of the cell has trials
+func (c *Cell) Reached() bool {
+	for action := range c.Data {
+		if c.Has(action) && c.Data[action].Trials > 0 {
+			return true
+		}
+	}
+	return false
+}
 
+type simTable struct {
+	cells      [][DealerSlots][PlayerSlots][CategorySlots]Cell // ContextSlots rows per true count bucket
+	countRange int                                             // true count buckets -countRange .. countRange
+	insurance  []SimData                                       // insurance side bet per true count bucket
+	meta       Metadata
+}
+
+func newSimTable(countRange int) *simTable {
+	buckets := 2*countRange + 1
+	return &simTable{
+		cells:      make([][DealerSlots][PlayerSlots][CategorySlots]Cell, ContextSlots*buckets),
+		countRange: countRange,
+		insurance:  make([]SimData, buckets),
+	}
+}
+
+// bucket returns the index of a true count bucket, counts beyond the range fall
+// in the edge buckets
+func (t *simTable) bucket(count int) int {
+	if count < -t.countRange {
+		count = -t.countRange
+	}
+	if count > t.countRange {
+		count = t.countRange
+	}
+	return count + t.countRange
+}
+
+// row returns the table row of a context
+func (t *simTable) row(ctx Context) int {
+	return t.bucket(ctx.Count)*ContextSlots + ctx.index()
+}
+
+// context returns the context of a table row
+func (t *simTable) context(row int) Context {
+	return contextAt(row%ContextSlots, row/ContextSlots-t.countRange)
+}
+
+// SimDataMap - dense table of SimData over decision context, dealer score,
+// player key, hand category and chosen action. Copies share the table (like the map it replaced);
+// the zero value has no table - use CreateSimDataStructure or NewSimDataMap.
+type SimDataMap struct {
+	t *simTable
+}
+
+// NewSimDataMap returns a dataset with an empty layout
+func NewSimDataMap() SimDataMap {
+	return SimDataMap{t: newSimTable(0)}
+}
+
+// CreateSimDataStructure returns a dataset with the layout of every reachable
+// decision and no trials
 func CreateSimDataStructure() SimDataMap {
-	ds := make(map[int]map[int]map[int]map[int]SimData)
+	return CreateCountedStructure(0)
+}
+
+// CreateCountedStructure returns a dataset with the layout of every reachable
+// decision in every true count bucket from -countRange to countRange
+func CreateCountedStructure(countRange int) SimDataMap {
+	ds := SimDataMap{t: newSimTable(countRange)}
 
-	for i := 1; i <= 10; i++ { // dealer shown score
-		for j := 2; j <= 20; j++ { // player score
+	for c := range ds.t.cells { // first / later decision, before / after a split, per count
+		later := ds.t.context(c).Later
+		cells := &ds.t.cells[c]
+		for i := 1; i <= 10; i++ { // dealer shown score
 
-			loopList := []int{0}
-			if j < 12 && j > 2{
-				loopList = append(loopList, 1)
+			// hard totals - two card hard 4 is always a pair
+			minHard := 5
+			if later {
+				minHard = 6
 			}
-			if j%2 == 0 {
-				loopList = append(loopList, 2)
+			for j := minHard; j <= 20; j++ {
+				cells[i][j][0].Layout = 0b0111 // stand, hit, double down
 			}
-			for _, k := range loopList { // player ace boolean
-
-				player_options := 3
-				if k == 2 {
-					player_options = 4
+			// soft totals - soft 12 is always a pair of aces
+			for j := 13; j <= 21; j++ {
+				cells[i][j][1].Layout = 0b0111
+			}
+			// pairs by rank - only ever two cards (resplits after a split)
+			if !later {
+				for j := 1; j <= 10; j++ {
+					cells[i][j][2].Layout = 0b1111 // stand, hit, double down, split
 				}
+			}
+		}
+	}
 
-				for l := 0; l < player_options; l++ { // actions [hit, stand, double down, split]
-					// Initialize the data structure with zero values
-					simData := SimData{
-						ExpectedValue: 0,
-						Trials:       0,
-					}
-					// Add simData to the simulation data structure
-					//fmt.Println(i, j, k == 1, l)
-					if _, ok := ds[i]; !ok {
-						ds[i] = make(map[int]map[int]map[int]SimData)
-					}
-					if _, ok := ds[i][j]; !ok {
-						ds[i][j] = make(map[int]map[int]SimData)
-					}
-					if _, ok := ds[i][j][k]; !ok {
-						ds[i][j][k] = make(map[int]SimData)
+	return ds
+}
+
+// IsZero reports whether the dataset has no table (e.g. a failed load)
+func (sdm SimDataMap) IsZero() bool {
+	return sdm.t == nil
+}
+
+// CountRange returns the largest true count bucket, 0 for datasets without counting
+func (sdm SimDataMap) CountRange() int {
+	if sdm.t == nil {
+		return 0
+	}
+	return sdm.t.countRange
+}
+
+// Insurance returns the record of the insurance side bet (per unit of the main
+// bet) at a true count bucket, nil if the dataset has no table
+func (sdm SimDataMap) Insurance(count int) *SimData {
+	if sdm.t == nil {
+		return nil
+	}
+	return &sdm.t.insurance[sdm.t.bucket(count)]
+}
+
+// Cell returns the records of one situation, nil if it is not in the layout
+func (sdm SimDataMap) Cell(ctx Context, dealer, player, category int) *Cell {
+	if sdm.t == nil || dealer < 0 || dealer >= DealerSlots || player < 0 || player >= PlayerSlots ||
+		category < 0 || category >= CategorySlots {
+		return nil
+	}
+	c := &sdm.t.cells[sdm.t.row(ctx)][dealer][player][category]
+	if c.Layout == 0 {
+		return nil
+	}
+	return c
+}
+
+// Get returns the record at k, false if it is not in the layout
+func (sdm SimDataMap) Get(k Key) (SimData, bool) {
+	c := sdm.Cell(k.Context, k.Dealer, k.Player, k.Category)
+	if c == nil || k.Action < 0 || k.Action >= ActionSlots || !c.Has(k.Action) {
+		return SimData{}, false
+	}
+	return c.Data[k.Action], true
+}
+
+// Set stores the record at k, adding it to the layout. False if k is outside of the table.
+func (sdm SimDataMap) Set(k Key, sd SimData) bool {
+	if sdm.t == nil || k.Dealer < 0 || k.Dealer >= DealerSlots || k.Player < 0 || k.Player >= PlayerSlots ||
+		k.Category < 0 || k.Category >= CategorySlots || k.Action < 0 || k.Action >= ActionSlots {
+		return false
+	}
+	c := &sdm.t.cells[sdm.t.row(k.Context)][k.Dealer][k.Player][k.Category]
+	c.Data[k.Action] = sd
+	c.Layout |= 1 << k.Action
+	return true
+}
+
+// EachCell calls fn for every cell in the layout in key order
+func (sdm SimDataMap) EachCell(fn func(ctx Context, dealer, player, category int, c *Cell)) {
+	if sdm.t == nil {
+		return
+	}
+	for h := range sdm.t.cells {
+		for i := range sdm.t.cells[h] {
+			for j := range sdm.t.cells[h][i] {
+				for k := range sdm.t.cells[h][i][j] {
+					if c := &sdm.t.cells[h][i][j][k]; c.Layout != 0 {
+						fn(sdm.t.context(h), i, j, k, c)
 					}
-					ds[i][j][k][l] = simData
 				}
 			}
-			
 		}
 	}
+}
 
-	return ds
+// Each calls fn for every record in the layout in key order
+func (sdm SimDataMap) Each(fn func(k Key, sd SimData)) {
+	sdm.EachCell(func(ctx Context, dealer, player, category int, c *Cell) {
+		for action := range c.Data {
+			if c.Has(action) {
+				fn(Key{ctx, dealer, player, category, action}, c.Data[action])
+			}
+		}
+	})
 }
 
 func (sdm SimDataMap) AddData(data SimState) {
 	// add data to the simulation data structure
 	for _, d := range data.SimEvalData {
-		dealerStart := d.DealerStart
-		playerScore := d.PlayerScores
-		playerHandCat := d.PlayerHandCats
-		chosenAction := d.ChoosenAction
-
-		// update the expected value and trials
-		simData := sdm[dealerStart][playerScore][playerHandCat][chosenAction]
-		simData.ExpectedValue = float32((simData.ExpectedValue*float32(simData.Trials) + float32(d.Value)) / float32(simData.Trials+1))
-		simData.Trials++
-
 		// ! debug, remove later
-		//fmt.Println("Adding data: DSS:", dealerStart, " PS:", playerScore, " cat:", playerHandCat, " Act:", chosenAction, " V:", d.Value)
-		if _, ok := sdm[dealerStart]; !ok {
-			fmt.Printf("Error: Dealer score %d not found in SimDataMap\n", dealerStart)
+		//fmt.Println("Adding data: DSS:", d.DealerStart, " PS:", d.PlayerScores, " cat:", d.PlayerHandCats, " Act:", d.ChoosenAction, " V:", d.Value)
+		cell := sdm.Cell(d.Context, d.DealerStart, d.PlayerScores, d.PlayerHandCats)
+		if cell == nil {
+			fmt.Printf("Error: Player hand category %d not found for %s decision, dealer score %d and player key %d in SimDataMap\n", d.PlayerHandCats, d.Context, d.DealerStart, d.PlayerScores)
 			continue
 		}
-		if _, ok := sdm[dealerStart][playerScore]; !ok {
-			fmt.Printf("Error: Player score %d not found for dealer score %d in SimDataMap\n", playerScore, dealerStart)
-			continue
+
+		// update the expected value, variance and trials in place
+		cell.Data[d.ChoosenAction].add(float64(d.Value))
+		cell.Layout |= 1 << d.ChoosenAction
+	}
+
+	for _, ins := range data.Insurance {
+		sdm.Insurance(ins.Count).add(float64(ins.Value))
+	}
+
+	// paired differences between the actions played from the same node
+	for _, n := range data.Nodes {
+		cell := sdm.Cell(n.Context, n.DealerStart, n.PlayerScores, n.PlayerHandCats)
+		if cell == nil {
+			continue // reported above
 		}
-		if _, ok := sdm[dealerStart][playerScore][playerHandCat]; !ok {
-			fmt.Printf("Error: Player hand category %d not found for dealer score %d and player score %d in SimDataMap\n", playerHandCat, dealerStart, playerScore)
-			continue
+		for a := 0; a < len(n.Values); a++ {
+			if n.Played&(1<<a) == 0 {
+				continue
+			}
+			for b := a + 1; b < len(n.Values); b++ {
+				if n.Played&(1<<b) != 0 {
+					cell.Diffs[PairIndex(a, b)].add(float64(n.Values[a] - n.Values[b]))
+				}
+			}
 		}
-		sdm[dealerStart][playerScore][playerHandCat][chosenAction] = simData
 	}
 }
 
+// absorb merges the records of shard into sdm (trials weighted mean, pooled variance)
+func (sdm SimDataMap) absorb(shard SimDataMap) {
+	shard.EachCell(func(ctx Context, i, j, k int, s *Cell) {
+		if !s.Reached() {
+			return
+		}
+		d := sdm.Cell(ctx, i, j, k)
+		if d == nil {
+			fmt.Printf("Error: cell %s/%d/%d/%d not found in SimDataMap\n", ctx, i, j, k)
+			return
+		}
+		for l := range s.Data {
+			if s.Has(l) && s.Data[l].Trials > 0 {
+				d.Data[l].merge(s.Data[l])
+				d.Layout |= 1 << l
+			}
+		}
+		for p := range s.Diffs {
+			d.Diffs[p].merge(s.Diffs[p])
+		}
+	})
+	sdm.mergeInsurance(shard)
+}
+
+// clear drops every record of sdm and keeps its layout, so a worker shard is
+// reused for the next batch once absorbed
+func (sdm SimDataMap) clear() {
+	sdm.EachCell(func(ctx Context, i, j, k int, c *Cell) {
+		*c = Cell{Layout: c.Layout}
+	})
+	for b := range sdm.t.insurance {
+		sdm.t.insurance[b] = SimData{}
+	}
+}
+
+// mergeInsurance merges the insurance records of o into sdm
+func (sdm SimDataMap) mergeInsurance(o SimDataMap) {
+	for b, sd := range o.t.insurance {
+		sdm.Insurance(b - o.t.countRange).merge(sd)
+	}
+}
+
+// SameLayout reports whether both datasets hold exactly the same records
+func (sdm SimDataMap) SameLayout(o SimDataMap) bool {
+	if sdm.t == nil || o.t == nil {
+		return sdm.t == o.t
+	}
+	if sdm.t.countRange != o.t.countRange {
+		return false
+	}
+	for h := range sdm.t.cells {
+		for i := range sdm.t.cells[h] {
+			for j := range sdm.t.cells[h][i] {
+				for k := range sdm.t.cells[h][i][j] {
+					if sdm.t.cells[h][i][j][k].Layout != o.t.cells[h][i][j][k].Layout {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Merge combines the records of o into sdm by trials weighted mean and pooled
+// variance, so datasets from independent runs add up to one larger run. Records
+// only o holds are added to the layout. True count buckets beyond the range of
+// sdm are merged into its edge buckets.
+func (sdm SimDataMap) Merge(o SimDataMap) {
+	sdm.t.meta.merge(o.t.meta)
+	o.EachCell(func(ctx Context, i, j, k int, s *Cell) {
+		d := &sdm.t.cells[sdm.t.row(ctx)][i][j][k]
+		for l := range s.Data {
+			if s.Has(l) {
+				d.Data[l].merge(s.Data[l])
+				d.Layout |= 1 << l
+			}
+		}
+		for p := range s.Diffs {
+			d.Diffs[p].merge(s.Diffs[p])
+		}
+	})
+	sdm.mergeInsurance(o)
+}
+
+// Pooled returns the records of every true count bucket merged into one, as if
+// the dataset had been recorded without counting. Datasets without counting are
+// returned as they are.
+func (sdm SimDataMap) Pooled() SimDataMap {
+	if sdm.CountRange() == 0 {
+		return sdm
+	}
+	out := NewSimDataMap()
+	out.Merge(sdm)
+	out.t.meta.Count, out.t.meta.CountRange, out.t.meta.Penetration = "", 0, 0
+	return out
+}
+
+// TotalTrials sums the trials of every record
+func (sdm SimDataMap) TotalTrials() int {
+	total := 0
+	sdm.Each(func(_ Key, sd SimData) {
+		total += sd.Trials
+	})
+	return total
+}
+
+// PrintStdErrSummary reports the median and largest standard error over all recorded actions
+func (sdm SimDataMap) PrintStdErrSummary() {
+	ses := make([]float64, 0)
+	worst, worstKey := 0.0, ""
+	sdm.Each(func(k Key, sd SimData) {
+		se, ok := sd.StdErr()
+		if !ok {
+			return
+		}
+		ses = append(ses, se)
+		if se > worst {
+			worst = se
+			worstKey = fmt.Sprintf("%s decision, dealer %d, player %d, cat %d, action %d", k.Context, k.Dealer, k.Player, k.Category, k.Action)
+		}
+	})
+	if len(ses) == 0 {
+		fmt.Println("Standard error: no variance recorded yet")
+		return
+	}
+	sort.Float64s(ses)
+	fmt.Printf("Standard error: median %.5f, max %.5f (%s)\n", ses[len(ses)/2], worst, worstKey)
+}
+
+// ----------------------------------------------------------------------------
+// JSON layout - {"Metadata": {...}, "Data": nested objects keyed by context name,
+// dealer score, player key, category and action}. "Insurance" holds the insurance
+// records by true count bucket. Files keyed by dealer score at the top (legacy
+// keys, see fromLegacy) are still read, with or without the metadata wrapper.
+
+type jsonLayout map[int]map[int]map[int]map[int]SimData
+
+// paired differences keyed by dealer score, player key, category and "a-b" action pair
+type jsonPaired map[int]map[int]map[int]map[string]SimData
+
+type jsonDataset struct {
+	Metadata  *Metadata
+	Data      json.RawMessage
+	Paired    json.RawMessage `json:",omitempty"`
+	Insurance map[int]SimData `json:",omitempty"`
+}
+
+func pairName(a, b int) string {
+	return fmt.Sprintf("%d-%d", a, b)
+}
+
+func parsePairName(name string) (int, error) {
+	var a, b int
+	if _, err := fmt.Sscanf(name, "%d-%d", &a, &b); err != nil || a < 0 || a >= b || b >= ActionSlots {
+		return 0, fmt.Errorf("invalid action pair %q", name)
+	}
+	return PairIndex(a, b), nil
+}
+
+// MarshalJSON writes the metadata and the records in the layout as nested objects
+func (sdm SimDataMap) MarshalJSON() ([]byte, error) {
+	if sdm.t == nil {
+		return []byte("null"), nil
+	}
+	ds := make(map[string]jsonLayout)
+	sdm.Each(func(k Key, sd SimData) {
+		name := k.Context.String()
+		if _, ok := ds[name]; !ok {
+			ds[name] = make(jsonLayout)
+		}
+		layout := ds[name]
+		if _, ok := layout[k.Dealer]; !ok {
+			layout[k.Dealer] = make(map[int]map[int]map[int]SimData)
+		}
+		if _, ok := layout[k.Dealer][k.Player]; !ok {
+			layout[k.Dealer][k.Player] = make(map[int]map[int]SimData)
+		}
+		if _, ok := layout[k.Dealer][k.Player][k.Category]; !ok {
+			layout[k.Dealer][k.Player][k.Category] = make(map[int]SimData)
+		}
+		layout[k.Dealer][k.Player][k.Category][k.Action] = sd
+	})
+	data, err := json.Marshal(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	paired := make(map[string]jsonPaired)
+	sdm.EachCell(func(ctx Context, i, j, k int, c *Cell) {
+		for a := 0; a < ActionSlots; a++ {
+			for b := a + 1; b < ActionSlots; b++ {
+				diff := c.Diffs[PairIndex(a, b)]
+				if diff.Trials == 0 {
+					continue
+				}
+				name := ctx.String()
+				if _, ok := paired[name]; !ok {
+					paired[name] = make(jsonPaired)
+				}
+				p := paired[name]
+				if _, ok := p[i]; !ok {
+					p[i] = make(map[int]map[int]map[string]SimData)
+				}
+				if _, ok := p[i][j]; !ok {
+					p[i][j] = make(map[int]map[string]SimData)
+				}
+				if _, ok := p[i][j][k]; !ok {
+					p[i][j][k] = make(map[string]SimData)
+				}
+				p[i][j][k][pairName(a, b)] = diff
+			}
+		}
+	})
+	var pairedData json.RawMessage
+	if len(paired) > 0 {
+		if pairedData, err = json.Marshal(paired); err != nil {
+			return nil, err
+		}
+	}
+	var insurance map[int]SimData
+	for b, sd := range sdm.t.insurance {
+		if sd.Trials > 0 {
+			if insurance == nil {
+				insurance = make(map[int]SimData)
+			}
+			insurance[b-sdm.t.countRange] = sd
+		}
+	}
+	return json.Marshal(jsonDataset{Metadata: &sdm.t.meta, Data: data, Paired: pairedData, Insurance: insurance})
+}
+
+// UnmarshalJSON reads the metadata and nested object layout, every key present
+// joins the layout
+func (sdm *SimDataMap) UnmarshalJSON(data []byte) error {
+	var wrapped jsonDataset
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+	if wrapped.Data != nil {
+		data = wrapped.Data
+	}
+
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return err
+	}
+	if top == nil {
+		*sdm = SimDataMap{}
+		return nil
+	}
+	var meta Metadata
+	if wrapped.Metadata != nil {
+		meta = *wrapped.Metadata
+	}
+	for name := range top {
+		if _, err := strconv.Atoi(name); err == nil {
+			return sdm.unmarshalLegacy(meta, data, wrapped.Paired)
+		}
+	}
+
+	// the table spans the recorded true count buckets
+	countRange := meta.CountRange
+	contexts := make(map[string]Context, len(top))
+	for name := range top {
+		ctx, err := ParseContext(name)
+		if err != nil {
+			return err
+		}
+		contexts[name] = ctx
+		if ctx.Count > countRange {
+			countRange = ctx.Count
+		} else if -ctx.Count > countRange {
+			countRange = -ctx.Count
+		}
+	}
+
+	out := SimDataMap{t: newSimTable(countRange)}
+	out.t.meta = meta
+	for name, raw := range top {
+		ctx := contexts[name]
+		var ds jsonLayout
+		if err := json.Unmarshal(raw, &ds); err != nil {
+			return err
+		}
+		for i, dealerMap := range ds {
+			for j, playerMap := range dealerMap {
+				for k, categoryMap := range playerMap {
+					for l, sd := range categoryMap {
+						if !out.Set(Key{ctx, i, j, k, l}, sd) {
+							return fmt.Errorf("dataset key %s/%d/%d/%d/%d is outside of the table", ctx, i, j, k, l)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var paired map[string]jsonPaired
+	if wrapped.Paired != nil {
+		if err := json.Unmarshal(wrapped.Paired, &paired); err != nil {
+			return err
+		}
+	}
+	for name, p := range paired {
+		ctx, err := ParseContext(name)
+		if err != nil {
+			return err
+		}
+		for i, dealerMap := range p {
+			for j, playerMap := range dealerMap {
+				for k, pairMap := range playerMap {
+					cell := out.Cell(ctx, i, j, k)
+					if cell == nil {
+						return fmt.Errorf("paired differences for %s/%d/%d/%d outside of the layout", ctx, i, j, k)
+					}
+					for name, diff := range pairMap {
+						pair, err := parsePairName(name)
+						if err != nil {
+							return err
+						}
+						cell.Diffs[pair] = diff
+					}
+				}
+			}
+		}
+	}
+	for count, sd := range wrapped.Insurance {
+		*out.Insurance(count) = sd
+	}
+	*sdm = out
+	return nil
+}
+
+// unmarshalLegacy reads records keyed by dealer score at the top (legacy keys)
+func (sdm *SimDataMap) unmarshalLegacy(meta Metadata, data, pairedData json.RawMessage) error {
+	var ds jsonLayout
+	if err := json.Unmarshal(data, &ds); err != nil {
+		return err
+	}
+	var paired jsonPaired
+	if pairedData != nil {
+		if err := json.Unmarshal(pairedData, &paired); err != nil {
+			return err
+		}
+	}
+
+	var records []legacyRecord
+	for i, dealerMap := range ds {
+		for j, playerMap := range dealerMap {
+			for k, categoryMap := range playerMap {
+				for l, sd := range categoryMap {
+					records = append(records, legacyRecord{i, j, k, l, sd})
+				}
+			}
+		}
+	}
+	var pairs []legacyPair
+	for i, dealerMap := range paired {
+		for j, playerMap := range dealerMap {
+			for k, pairMap := range playerMap {
+				for name, diff := range pairMap {
+					pair, err := parsePairName(name)
+					if err != nil {
+						return err
+					}
+					pairs = append(pairs, legacyPair{i, j, k, pair, diff})
+				}
+			}
+		}
+	}
+
+	out, err := fromLegacy(meta, records, pairs)
+	if err != nil {
+		return err
+	}
+	*sdm = out
+	return nil
+}
 
 // ----------------------------------------------------------------------------
 
 // SimDataMap to JSON "bj_sim_data.json"
 func (sdm SimDataMap) ToJSON() ([]byte, error) {
+	return sdm.WriteJSON("bj_sim_data.json")
+}
+
+// SimDataMap to JSON at the given path (temp file + rename, never half written)
+func (sdm SimDataMap) WriteJSON(filename string) ([]byte, error) {
 	
 	data, err := json.Marshal(sdm)
 	if err != nil {
 		return nil, err
 	}
 
-	err = os.WriteFile("bj_sim_data.json", data, 0644)
+	err = writeFileAtomic(filename, data)
 	if err != nil {
 		fmt.Println("Error writing to file:", err)
 		return nil, err
@@ -127,16 +778,28 @@ func (sdm SimDataMap) ToJSON() ([]byte, error) {
 	return data, nil
 }
 
+// Save writes the dataset to filename in the format its name selects, first keeping
+// the previous file as a rotated snapshot (filename.1 newest .. filename.<snapshots>)
+func (sdm SimDataMap) Save(filename string, snapshots int) error {
+	if err := rotateSnapshots(filename, snapshots); err != nil {
+		return fmt.Errorf("rotating snapshots of %s: %w", filename, err)
+	}
+	return sdm.WriteFile(filename)
+}
+
 // load data from JSON file to SimDataMap
 func LoadFromJSON(filename string) (SimDataMap, error) {
 	file, err := os.ReadFile(filename)
 	if err != nil {	
-		return nil, err
+		return SimDataMap{}, err
 	}
 	var sdm SimDataMap
 	err = json.Unmarshal(file, &sdm)
 	if err != nil {
-		return nil, err
+		return SimDataMap{}, err
+	}
+	if sdm.IsZero() {
+		return SimDataMap{}, fmt.Errorf("%s: no dataset", filename)
 	}
 	return sdm, nil
 }
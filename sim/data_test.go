@@ -0,0 +1,215 @@
+package sim
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// merging the parts of a sample (Chan et al.) gives the mean and variance of
+// adding all its values one by one (Welford)
+func TestSimDataMerge(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = 2*rng.NormFloat64() + 0.3
+	}
+
+	var whole SimData
+	for _, v := range values {
+		whole.add(v)
+	}
+	for _, cuts := range [][]int{
+		{500},
+		{1, 999},
+		{0, 10, 10, 600},
+		{250, 500, 750},
+	} {
+		var merged SimData
+		from := 0
+		for _, to := range append(cuts, len(values)) {
+			var part SimData
+			for _, v := range values[from:to] {
+				part.add(v)
+			}
+			merged.merge(part)
+			from = to
+		}
+		if merged.Trials != whole.Trials || merged.VarTrials != whole.VarTrials {
+			t.Errorf("cuts %v: %d / %d trials, want %d / %d", cuts, merged.Trials, merged.VarTrials, whole.Trials, whole.VarTrials)
+		}
+		if math.Abs(merged.ExpectedValue-whole.ExpectedValue) > 1e-12 || math.Abs(merged.M2-whole.M2) > 1e-9 {
+			t.Errorf("cuts %v: mean %v M2 %v, want %v %v", cuts, merged.ExpectedValue, merged.M2, whole.ExpectedValue, whole.M2)
+		}
+	}
+
+	// records without variance (older datasets) still weigh in the mean
+	sd := SimData{ExpectedValue: 1, Trials: 3}
+	sd.merge(SimData{ExpectedValue: -1, Trials: 1})
+	if sd.ExpectedValue != 0.5 || sd.Trials != 4 || sd.VarTrials != 0 {
+		t.Errorf("merge without variance: %+v", sd)
+	}
+}
+
+func TestSimTableLayout(t *testing.T) {
+	sdm := CreateCountedStructure(2)
+	cells := map[string]int{}
+	sdm.EachCell(func(ctx Context, i, j, k int, c *Cell) {
+		if ctx.Count == 1 {
+			cells[ctx.Decision()]++
+		}
+	})
+	// 10 upcards of hard 5-20, soft 13-21 and 10 pairs; no pairs or hard 5 later
+	want := map[string]int{"first": 350, "later": 240, "split": 350, "split-later": 240}
+	for decision, n := range want {
+		if cells[decision] != n {
+			t.Errorf("%s decisions: %d cells, want %d", decision, cells[decision], n)
+		}
+	}
+
+	// every table row maps back onto its context, counts beyond the range are clamped
+	for row := range sdm.t.cells {
+		if got := sdm.t.row(sdm.t.context(row)); got != row {
+			t.Errorf("row %d: context %s is row %d", row, sdm.t.context(row), got)
+		}
+	}
+	if sdm.Cell(Context{Count: 5}, 10, 16, 0) != sdm.Cell(Context{Count: 2}, 10, 16, 0) {
+		t.Errorf("true count +5 is not in the +2 bucket")
+	}
+}
+
+func TestSimTableGetSet(t *testing.T) {
+	sdm := CreateSimDataStructure()
+	k := Key{Dealer: 6, Player: 16, Action: ActionHit}
+	if sd, ok := sdm.Get(k); !ok || sd.Trials != 0 {
+		t.Errorf("hit on hard 16 v 6: %+v, %v", sd, ok)
+	}
+	if _, ok := sdm.Get(Key{Dealer: 6, Player: 16, Action: ActionSplit}); ok {
+		t.Errorf("a hard total has a split record")
+	}
+	if sdm.Cell(Context{Later: true}, 6, 8, 2) != nil {
+		t.Errorf("a later decision has a pair")
+	}
+
+	// copies share the table
+	alias := sdm
+	if !alias.Set(k, SimData{ExpectedValue: -0.4, Trials: 3}) {
+		t.Fatal("set hit on hard 16 v 6")
+	}
+	if sd, _ := sdm.Get(k); sd.Trials != 3 {
+		t.Errorf("set through a copy: %+v", sd)
+	}
+	// Set adds to the layout, within the table only
+	surrender := Key{Dealer: 6, Player: 16, Action: ActionSurrender}
+	if !sdm.Set(surrender, SimData{ExpectedValue: -0.5, Trials: 1}) || !sdm.Cell(Context{}, 6, 16, 0).Has(ActionSurrender) {
+		t.Errorf("surrender not added to the layout")
+	}
+	for _, out := range []Key{{Dealer: 11}, {Player: 22}, {Category: 3}, {Action: ActionSlots}, {Dealer: -1}} {
+		if sdm.Set(out, SimData{}) {
+			t.Errorf("set %+v outside of the table", out)
+		}
+	}
+	if (SimDataMap{}).Cell(Context{}, 6, 16, 0) != nil {
+		t.Errorf("zero dataset has a cell")
+	}
+}
+
+func TestSimDataStatistics(t *testing.T) {
+	var sd SimData
+	sd.add(1)
+	if _, ok := sd.StdErr(); ok {
+		t.Errorf("standard error of one trial")
+	}
+	if data, _ := json.Marshal(sd); strings.Contains(string(data), "StdError") {
+		t.Errorf("one trial marshalled with a standard error: %s", data)
+	}
+	for _, v := range []float64{-1, 1, 1} {
+		sd.add(v)
+	}
+	// mean 0.5, sample variance (0.25+2.25+0.25+0.25)/3 = 1
+	v, _ := sd.Variance()
+	se, _ := sd.StdErr()
+	ci, ok := sd.CI95()
+	if sd.ExpectedValue != 0.5 || math.Abs(v-1) > 1e-12 || math.Abs(se-0.5) > 1e-12 || !ok ||
+		math.Abs(ci[0]-(0.5-0.98)) > 1e-12 || math.Abs(ci[1]-(0.5+0.98)) > 1e-12 {
+		t.Errorf("mean %g variance %g std error %g CI %v, want 0.5, 1, 0.5, [-0.48, 1.48]", sd.ExpectedValue, v, se, ci)
+	}
+	data, err := json.Marshal(sd)
+	if err != nil || !strings.Contains(string(data), `"StdError":0.5`) || !strings.Contains(string(data), `"CI95":[`) {
+		t.Errorf("marshalled %s, %v", data, err)
+	}
+}
+
+// Merge adds up two datasets record by record, and absorb / clear keep a
+// worker shard reusable
+func TestSimDataMapMerge(t *testing.T) {
+	a, b := testDataset(2, 1), testDataset(2, 2)
+	want := CreateCountedStructure(2)
+	for _, part := range []SimDataMap{a, b} {
+		part.EachCell(func(ctx Context, i, j, k int, c *Cell) {
+			w := want.Cell(ctx, i, j, k)
+			for action := range c.Data {
+				w.Data[action].merge(c.Data[action])
+			}
+			for p := range c.Diffs {
+				w.Diffs[p].merge(c.Diffs[p])
+			}
+		})
+		want.mergeInsurance(part)
+	}
+
+	merged := testDataset(2, 1)
+	merged.Merge(b)
+	closeRecords(t, merged, want)
+	if got := merged.Metadata().Hands; got != 2000 {
+		t.Errorf("merged hands %d, want 2000", got)
+	}
+
+	absorbed := testDataset(2, 1)
+	shard := testDataset(2, 2)
+	absorbed.absorb(shard)
+	closeRecords(t, absorbed, want)
+
+	shard.clear()
+	if shard.TotalTrials() != 0 || !shard.SameLayout(b) {
+		t.Errorf("cleared shard: %d trials, same layout %v", shard.TotalTrials(), shard.SameLayout(b))
+	}
+
+	// every count pooled: the uncounted totals
+	pooled := merged.Pooled()
+	if pooled.CountRange() != 0 || pooled.TotalTrials() != merged.TotalTrials() {
+		t.Errorf("pooled: range %d, %d trials, want 0, %d", pooled.CountRange(), pooled.TotalTrials(), merged.TotalTrials())
+	}
+}
+
+// closeRecords is sameRecords up to the rounding of merging in another order
+func closeRecords(t *testing.T, got, want SimDataMap) {
+	t.Helper()
+	if !got.SameLayout(want) {
+		t.Fatal("layouts differ")
+	}
+	close := func(g, w SimData) bool {
+		return g.Trials == w.Trials && g.VarTrials == w.VarTrials &&
+			math.Abs(g.ExpectedValue-w.ExpectedValue) < 1e-12 && math.Abs(g.M2-w.M2) < 1e-9
+	}
+	want.EachCell(func(ctx Context, i, j, k int, w *Cell) {
+		g := got.Cell(ctx, i, j, k)
+		for action := range w.Data {
+			if !close(g.Data[action], w.Data[action]) {
+				t.Fatalf("cell %s/%d/%d/%d action %d: %+v, want %+v", ctx, i, j, k, action, g.Data[action], w.Data[action])
+			}
+		}
+		for p := range w.Diffs {
+			if !close(g.Diffs[p], w.Diffs[p]) {
+				t.Fatalf("cell %s/%d/%d/%d pair %d: %+v, want %+v", ctx, i, j, k, p, g.Diffs[p], w.Diffs[p])
+			}
+		}
+	})
+	for b := -want.CountRange(); b <= want.CountRange(); b++ {
+		if g, w := *got.Insurance(b), *want.Insurance(b); !close(g, w) {
+			t.Fatalf("insurance at %+d: %+v, want %+v", b, g, w)
+		}
+	}
+}
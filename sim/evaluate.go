@@ -0,0 +1,150 @@
+package sim
+
+import (
+	"blackjack/counting"
+	"blackjack/game"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy chooses the action (ActionStand, ActionHit ...) for the hand being played.
+// Only actions legal in gs.PlayerMoves may be returned.
+type Policy interface {
+	Choose(gs *game.GameState) int
+}
+
+// EvalResult - outcome of playing a fixed strategy for many rounds
+type EvalResult struct {
+	Rounds int
+
+	Mean   float64 // EV per initial bet
+	StdDev float64 // standard deviation of a round's result
+	StdErr float64 // standard error of Mean
+	CI95   [2]float64
+
+	// round outcomes (mutually exclusive)
+	Wins       int // won, excluding blackjacks
+	Losses     int
+	Pushes     int
+	Blackjacks int // player natural that won
+
+	Elapsed time.Duration
+}
+
+// roundDeck returns a freshly shuffled shoe of decks decks (or an infinite deck)
+// drawn from rng
+func roundDeck(decks int, infinite bool, rng *rand.Rand) game.Deck {
+	if infinite {
+		return game.NewDeck(game.InfiniteDeck, rng)
+	}
+	return game.NewShoe(decks, rng)
+}
+
+// PlayRound plays each hand of the round through following the policy
+func PlayRound(gs *game.GameState, policy Policy) {
+	for gs.HandToPlay < len(gs.PlayerHand) {
+		action := policy.Choose(gs)
+		gs.ActionCalc(PlayerActions[action].actionMask)
+	}
+}
+
+// EvaluateStrategy plays rounds of blackjack following policy straight through
+// (no branch exploration), each from a fresh shoe of decks decks (or an infinite
+// deck) shuffled by rng, and reports the return per initial bet
+func EvaluateStrategy(rounds int, policy Policy, decks int, infinite bool, rng *rand.Rand) EvalResult {
+	startTime := time.Now()
+
+	var res EvalResult
+	var acc SimData // running mean / variance of a round's result
+
+	for i := 1; i <= rounds; i++ {
+		gs := game.StartRound(roundDeck(decks, infinite, rng))
+
+		total, _ := game.HandTotal(gs.PlayerHand[0])
+		natural := total == 21
+
+		PlayRound(&gs, policy)
+
+		value := 0.0
+		for _, v := range gs.HandValues {
+			value += float64(v)
+		}
+
+		switch {
+		case value > 0 && natural:
+			res.Blackjacks++
+		case value > 0:
+			res.Wins++
+		case value < 0:
+			res.Losses++
+		default:
+			res.Pushes++
+		}
+
+		acc.add(value)
+	}
+
+	res.Rounds = rounds
+	res.Mean = acc.ExpectedValue
+	if variance, ok := acc.Variance(); ok {
+		res.StdDev = math.Sqrt(variance)
+	}
+	res.StdErr, _ = acc.StdErr()
+	res.CI95, _ = acc.CI95()
+	res.Elapsed = time.Since(startTime)
+	return res
+}
+
+// Print human format evaluation summary
+func (res EvalResult) Print() {
+	pct := func(n int) float64 {
+		return float64(n) / float64(res.Rounds) * 100
+	}
+
+	fmt.Printf("Rounds played: %d (%s)\n", res.Rounds, res.Elapsed.Round(time.Millisecond))
+	fmt.Printf("EV per initial bet: %+.5f  (%+.3f%%)\n", res.Mean, res.Mean*100)
+	fmt.Printf("Std deviation: %.4f  Std error: %.6f\n", res.StdDev, res.StdErr)
+	fmt.Printf("95%% CI: [%+.5f, %+.5f]\n", res.CI95[0], res.CI95[1])
+	fmt.Printf("Wins: %.2f%%  Blackjacks: %.2f%%  Losses: %.2f%%  Pushes: %.2f%%\n",
+		pct(res.Wins), pct(res.Blackjacks), pct(res.Losses), pct(res.Pushes))
+}
+
+// ----------------------------------------------------------------------------
+// Dataset policy
+
+// DatasetPolicy plays the best legal action recorded in a SimDataMap
+// (same lookup node_explore uses when returning a node's value). With a Counter
+// watching the deck, a counted dataset is read at the counter's true count bucket.
+type DatasetPolicy struct {
+	Data    SimDataMap
+	Counter *counting.Counter
+}
+
+func (p DatasetPolicy) Choose(gs *game.GameState) int {
+	moves := gs.PlayerMoves[gs.HandToPlay]
+	hand_ctx, hand_key, hand_cat := HandState(gs)
+	if r := p.Data.CountRange(); r > 0 && p.Counter != nil {
+		hand_ctx.Count = counting.Bucket(p.Counter.True(), r)
+	}
+
+	best_action := ActionStand
+	var best_expected_value float64 = -1000
+
+	cell := p.Data.Cell(hand_ctx, gs.DealerShownScore, hand_key, hand_cat)
+	if cell == nil {
+		return best_action
+	}
+	for action, simData := range cell.Data {
+		if action >= len(PlayerActions) || !cell.Has(action) {
+			continue // e.g. surrender - not supported by the engine
+		}
+		legal := action == ActionStand || PlayerActions[action].actionMask&moves != 0
+		if legal && simData.Trials > 0 && simData.ExpectedValue > best_expected_value {
+			best_expected_value = simData.ExpectedValue
+			best_action = action
+		}
+	}
+	return best_action
+}
@@ -0,0 +1,86 @@
+package sim
+
+import (
+	"blackjack/counting"
+	"blackjack/game"
+	"math"
+	"testing"
+)
+
+// standPolicy stands on every hand
+type standPolicy struct{}
+
+func (standPolicy) Choose(gs *game.GameState) int { return ActionStand }
+
+func TestEvaluateStrategy(t *testing.T) {
+	play := func(seed int64, decks int, infinite bool) EvalResult {
+		rng, _ := game.NewRand(seed)
+		return EvaluateStrategy(20000, standPolicy{}, decks, infinite, rng)
+	}
+	res := play(1, 6, false)
+	if n := res.Wins + res.Losses + res.Pushes + res.Blackjacks; n != res.Rounds || res.Rounds != 20000 {
+		t.Errorf("%d outcomes of %d rounds, want 20000", n, res.Rounds)
+	}
+	// standing on everything never doubles or splits: every round is -1, 0 or +1
+	if res.Mean >= 0 || res.StdDev > 1.001 {
+		t.Errorf("always stand: EV %+.4f, std deviation %.4f", res.Mean, res.StdDev)
+	}
+	if lo, hi := res.Mean-1.96*res.StdErr, res.Mean+1.96*res.StdErr; math.Abs(res.CI95[0]-lo) > 1e-12 ||
+		math.Abs(res.CI95[1]-hi) > 1e-12 {
+		t.Errorf("95%% CI %v, want [%g, %g]", res.CI95, lo, hi)
+	}
+
+	// a seed repeats its rounds, another seed deals others
+	if again := play(1, 6, false); again.Mean != res.Mean || again.Wins != res.Wins || again.Pushes != res.Pushes {
+		t.Errorf("seed 1 again: EV %+.5f, want %+.5f", again.Mean, res.Mean)
+	}
+	if other := play(2, 6, false); other.Mean == res.Mean && other.Wins == res.Wins {
+		t.Errorf("seeds 1 and 2 played the same rounds")
+	}
+	if infinite := play(1, 0, true); infinite.Rounds != 20000 || infinite.Mean == res.Mean {
+		t.Errorf("infinite deck: %d rounds, EV %+.5f", infinite.Rounds, infinite.Mean)
+	}
+}
+
+// stackedRound deals the player a, b and the dealer up over a 7 in the hole
+func stackedRound(a, b, up int) game.GameState {
+	deck := game.Deck{Cards: []game.Card{{Rank: a}, {Rank: b}, {Rank: up}, {Rank: 7}, {Rank: 5}, {Rank: 9}}}
+	return game.StartRound(deck)
+}
+
+func TestDatasetPolicy(t *testing.T) {
+	sdm := CreateCountedStructure(2)
+	set := func(count, player, category int, evs map[int]float64) {
+		for action, ev := range evs {
+			k := Key{Context: Context{Count: count}, Dealer: 10, Player: player, Category: category, Action: action}
+			if !sdm.Set(k, record(ev, 0.01, 100)) {
+				t.Fatalf("no record %+v", k)
+			}
+		}
+	}
+	set(0, 8, 2, map[int]float64{ActionStand: -0.5, ActionHit: -0.4, ActionDouble: -0.8, ActionSplit: -0.3})
+	set(0, 16, 0, map[int]float64{ActionStand: -0.54, ActionHit: -0.53})
+	set(2, 16, 0, map[int]float64{ActionStand: -0.45, ActionHit: -0.52})
+
+	counter := counting.NewCounter(counting.HiLo, 52)
+	counter.Running = 4 // true count +4 with a deck left: bucket +2
+	pooled := DatasetPolicy{Data: sdm}
+	tests := []struct {
+		name   string
+		policy DatasetPolicy
+		a, b   int
+		up     int
+		want   int
+	}{
+		{"best action", pooled, 8, 8, 10, ActionSplit},
+		{"no records", pooled, 9, 9, 10, ActionStand},
+		{"no counter", pooled, 10, 6, 10, ActionHit},
+		{"true count bucket", DatasetPolicy{Data: sdm, Counter: &counter}, 10, 6, 10, ActionStand},
+	}
+	for _, tt := range tests {
+		gs := stackedRound(tt.a, tt.b, tt.up)
+		if got := tt.policy.Choose(&gs); got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, ActionNames[got], ActionNames[tt.want])
+		}
+	}
+}
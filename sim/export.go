@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// display names per action / hand category index
+var (
+	ActionNames   = [ActionSlots]string{"Stand", "Hit", "Double", "Split", "Surrender"}
+	CategoryNames = [CategorySlots]string{"Hard", "Soft", "Pair"}
+)
+
+// UpLabel names a dealer upcard or card value: "A", "2" .. "9", "T"
+func UpLabel(up int) string {
+	switch up {
+	case 1:
+		return "A"
+	case 10:
+		return "T"
+	}
+	return strconv.Itoa(up)
+}
+
+// HandLabel names the hands of a dataset player key and category, e.g. "12",
+// "A,6" (two card soft 17, "soft 17" beyond 21) or "8,8"
+func HandLabel(player, category int) string {
+	switch {
+	case category == 1 && player <= 21:
+		return "A," + UpLabel(player-11)
+	case category == 2:
+		return UpLabel(player) + "," + UpLabel(player)
+	}
+	return strconv.Itoa(player)
+}
+
+// Best returns the action with the highest EV among those with trials, false if
+// the cell has not been reached (same choice node_explore makes)
+func (c *Cell) Best() (int, bool) {
+	best, found := ActionStand, false
+	for action, sd := range c.Data {
+		if !c.Has(action) || sd.Trials == 0 {
+			continue
+		}
+		if !found || sd.ExpectedValue > c.Data[best].ExpectedValue {
+			best, found = action, true
+		}
+	}
+	return best, found
+}
+
+// tableHeader - columns of the tidy export
+var tableHeader = []string{
+	"context", "true_count", "dealer_up", "player_total", "category", "category_name", "hand", "action", "action_name",
+	"ev", "trials", "std_error", "ci95_low", "ci95_high", "is_best", "p_value", "resolved",
+}
+
+// WriteTable writes one row per (context, dealer up, player key, category, action)
+// in key order, the true count bucket of counted datasets in its own column, separated by comma (CSV) or '\t' (TSV). std_error and the interval
+// are empty when the record carries no variance yet. p_value and resolved (at
+// DefaultAlpha) describe the cell's best action against the runner-up and are
+// repeated on each of its rows; both are empty when the significance is unknown.
+func (sdm SimDataMap) WriteTable(w io.Writer, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write(tableHeader); err != nil {
+		return err
+	}
+
+	float := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	var err error
+	row := make([]string, len(tableHeader))
+	sdm.EachCell(func(ctx Context, dealer, player, category int, c *Cell) {
+		best, reached := c.Best()
+		sig := c.Significance()
+		for action, sd := range c.Data {
+			if err != nil || !c.Has(action) {
+				continue
+			}
+			row[0] = ctx.Decision()
+			row[1] = strconv.Itoa(ctx.Count)
+			row[2] = strconv.Itoa(dealer)
+			row[3] = strconv.Itoa(KeyTotal(player, category))
+			row[4] = strconv.Itoa(category)
+			row[5] = CategoryNames[category]
+			row[6] = HandLabel(player, category)
+			row[7] = strconv.Itoa(action)
+			row[8] = ActionNames[action]
+			row[9] = float(sd.ExpectedValue)
+			row[10] = strconv.Itoa(sd.Trials)
+			row[11], row[12], row[13] = "", "", ""
+			if se, ok := sd.StdErr(); ok {
+				ci, _ := sd.CI95()
+				row[11], row[12], row[13] = float(se), float(ci[0]), float(ci[1])
+			}
+			row[14] = strconv.FormatBool(reached && action == best)
+			row[15], row[16] = "", ""
+			if sig.Known {
+				row[15] = float(sig.PValue)
+				row[16] = strconv.FormatBool(sig.Resolved(DefaultAlpha))
+			}
+			err = cw.Write(row)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,80 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestHandLabel(t *testing.T) {
+	tests := []struct {
+		player, category int
+		want             string
+	}{
+		{12, 0, "12"},
+		{17, 1, "A,6"},
+		{21, 1, "A,T"},
+		{1, 2, "A,A"},
+		{8, 2, "8,8"},
+		{10, 2, "T,T"},
+	}
+	for _, tt := range tests {
+		if got := HandLabel(tt.player, tt.category); got != tt.want {
+			t.Errorf("HandLabel(%d, %d) = %q, want %q", tt.player, tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	sdm := CreateSimDataStructure()
+	sdm.Set(Key{Dealer: 10, Player: 8, Category: 2, Action: ActionStand}, record(-0.5, 0.01, 100))
+	sdm.Set(Key{Dealer: 10, Player: 8, Category: 2, Action: ActionSplit}, record(-0.45, 0.01, 100))
+	records := 0
+	sdm.Each(func(Key, SimData) { records++ })
+
+	for _, comma := range []rune{',', '\t'} {
+		var buf bytes.Buffer
+		if err := sdm.WriteTable(&buf, comma); err != nil {
+			t.Fatal(err)
+		}
+		r := csv.NewReader(&buf)
+		r.Comma = comma
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("%q: %v", comma, err)
+		}
+		if len(rows) != records+1 {
+			t.Fatalf("%q: %d rows, want a header and %d records", comma, len(rows), records)
+		}
+		for i, name := range tableHeader {
+			if rows[0][i] != name {
+				t.Errorf("%q: column %d is %q, want %q", comma, i, rows[0][i], name)
+			}
+		}
+
+		found := 0
+		for _, row := range rows[1:] {
+			if row[0] != "first" || row[2] != "10" || row[6] != "8,8" {
+				if row[10] != "0" || row[11] != "" || row[14] != "false" || row[15] != "" {
+					t.Errorf("%q: unreached record %v", comma, row)
+				}
+				continue
+			}
+			switch row[8] {
+			case "Split":
+				found++
+				if row[3] != "16" || row[9] != "-0.45" || row[10] != "100" || row[14] != "true" || row[11] == "" || row[15] == "" {
+					t.Errorf("%q: split 8,8 v T row %v", comma, row)
+				}
+			case "Stand":
+				found++
+				if row[14] != "false" {
+					t.Errorf("%q: stand 8,8 v T is marked best", comma)
+				}
+			}
+		}
+		if found != 2 {
+			t.Errorf("%q: %d of the 2 recorded rows", comma, found)
+		}
+	}
+}
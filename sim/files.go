@@ -0,0 +1,74 @@
+package sim
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to a temp file next to filename and renames it into
+// place, so a crash leaves either the old or the new file - never a partial one
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// rotateSnapshots shifts filename.1 .. filename.(keep-1) up by one and links the
+// current file as filename.1, dropping the oldest snapshot. filename itself stays
+// in place until the next write replaces it.
+func rotateSnapshots(filename string, keep int) error {
+	if keep < 1 {
+		return nil
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil // nothing saved yet
+	}
+
+	snapshot := func(n int) string {
+		return fmt.Sprintf("%s.%d", filename, n)
+	}
+	for n := keep - 1; n >= 1; n-- {
+		if err := os.Rename(snapshot(n), snapshot(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Link(filename, snapshot(1)); err != nil {
+		return copyFile(filename, snapshot(1)) // no hard links on this file system
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data)
+}
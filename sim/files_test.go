@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.json")
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(filename, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(filename); string(got) != content {
+			t.Errorf("file holds %q, want %q", got, content)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d files left in the directory, want only the written one", len(entries))
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "data.json"), nil); err == nil {
+		t.Errorf("write into a missing directory: no error")
+	}
+}
+
+func TestRotateSnapshots(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.json")
+	for i := 1; i <= 4; i++ {
+		if err := rotateSnapshots(filename, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFileAtomic(filename, []byte(fmt.Sprint("save ", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the newest snapshot is the save before the current one
+	for name, want := range map[string]string{"": "save 4", ".1": "save 3", ".2": "save 2"} {
+		if got, err := os.ReadFile(filename + name); err != nil || string(got) != want {
+			t.Errorf("data.json%s: %q (%v), want %q", name, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filename + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more snapshots kept than asked for")
+	}
+}
+
+// a dataset saved in any format loads back with its records
+func TestSaveLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+	want := testDataset(1, 3)
+	for _, name := range []string{"data.json", "data.json.gz", "data.bjsd", "data.bjsd.gz"} {
+		filename := filepath.Join(dir, name)
+		if err := want.Save(filename, 1); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := LoadDataset(filename)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		sameRecords(t, got, want)
+	}
+}
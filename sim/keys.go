@@ -0,0 +1,166 @@
+package sim
+
+import (
+	"blackjack/game"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Context - the circumstances of a decision beyond the cards and the dealer
+// upcard. The zero value is the first decision on a hand.
+type Context struct {
+	Later bool // the hand has three or more cards (many tables no longer allow a double)
+	Split bool // the hand came from a split: no natural, double / resplit depend on the rules
+	Count int  // true count bucket in counted datasets (see counting.Bucket), else 0
+}
+
+// ContextSlots - number of decision contexts per true count bucket (see Context.index)
+const ContextSlots = 4
+
+// context names, used as the outer JSON key (followed by " tc+n" for a true count bucket)
+var contextNames = [ContextSlots]string{"first", "later", "split", "split-later"}
+
+// index of the decision context within a true count bucket
+func (c Context) index() int {
+	i := 0
+	if c.Later {
+		i |= 1
+	}
+	if c.Split {
+		i |= 2
+	}
+	return i
+}
+
+func contextAt(i, count int) Context {
+	return Context{Later: i&1 != 0, Split: i&2 != 0, Count: count}
+}
+
+// Decision names the decision context without the count: "first", "later",
+// "split" or "split-later"
+func (c Context) Decision() string {
+	return contextNames[c.index()]
+}
+
+func (c Context) String() string {
+	if c.Count != 0 {
+		return fmt.Sprintf("%s tc%+d", c.Decision(), c.Count)
+	}
+	return c.Decision()
+}
+
+// ParseContext reads a context name: "first", "later", "split" or "split-later",
+// optionally followed by a true count bucket e.g. "first tc+2"
+func ParseContext(s string) (Context, error) {
+	name, count := s, 0
+	if i := strings.Index(s, " tc"); i >= 0 {
+		n, err := strconv.Atoi(s[i+3:])
+		if err != nil {
+			return Context{}, fmt.Errorf("invalid true count in decision context %q", s)
+		}
+		name, count = s[:i], n
+	}
+	for i, decision := range contextNames {
+		if name == decision {
+			return contextAt(i, count), nil
+		}
+	}
+	return Context{}, fmt.Errorf("unknown decision context %q", s)
+}
+
+// StateOf returns the dataset key of a player hand that did not come from a split
+// - its context, player key and category:
+//
+//	hard (0): hard total 4-21
+//	soft (1): soft total 12-21 (an ace counted as 11)
+//	pair (2): rank 1 (aces) - 10 of two cards of the same rank
+func StateOf(hand []game.Card) (ctx Context, player, category int) {
+	ctx.Later = len(hand) > 2
+	if len(hand) == 2 && hand[0].Rank == hand[1].Rank {
+		rank := hand[0].Rank
+		if rank > 10 {
+			rank = 10
+		}
+		return ctx, rank, 2
+	}
+	total, soft := game.HandTotal(hand)
+	if soft {
+		return ctx, total, 1
+	}
+	return ctx, total, 0
+}
+
+// HandState returns the dataset key of the hand being played. Once a hand is
+// split every hand of the round is a post-split hand.
+func HandState(gs *game.GameState) (ctx Context, player, category int) {
+	ctx, player, category = StateOf(gs.PlayerHand[gs.HandToPlay])
+	ctx.Split = len(gs.PlayerHand) > 1
+	return ctx, player, category
+}
+
+// KeyTotal returns the hand total of a player key: the total itself for hard and
+// soft hands, twice the rank for pairs (12 for aces)
+func KeyTotal(player, category int) int {
+	switch {
+	case category == 2 && player == 1:
+		return 12
+	case category == 2:
+		return 2 * player
+	}
+	return player
+}
+
+// ----------------------------------------------------------------------------
+// Legacy keys
+
+// Datasets saved before the state keys were refined keyed hands by their score
+// with aces as 1 and pairs by their total, without a context. They are re-filed
+// on load: soft scores gain 10, pairs go by rank and every record counts as a
+// first decision - these datasets mix first and later decisions on hard and soft
+// hands, so Metadata.KeyVersion stays 0 to mark them.
+
+type legacyRecord struct {
+	dealer, player, category, action int
+	sd                               SimData
+}
+
+type legacyPair struct {
+	dealer, player, category, pair int
+	diff                           SimData
+}
+
+// legacyPlayer maps a legacy player score onto the player key
+func legacyPlayer(player, category int) int {
+	switch category {
+	case 1:
+		return player + 10
+	case 2:
+		return player / 2
+	}
+	return player
+}
+
+// fromLegacy re-files legacy records into the standard layout. Records outside
+// of it are kept only when they have trials.
+func fromLegacy(meta Metadata, records []legacyRecord, pairs []legacyPair) (SimDataMap, error) {
+	out := CreateSimDataStructure()
+	out.t.meta = meta
+	for _, r := range records {
+		k := Key{Dealer: r.dealer, Player: legacyPlayer(r.player, r.category), Category: r.category, Action: r.action}
+		if _, ok := out.Get(k); !ok && r.sd.Trials == 0 {
+			continue // empty record the new layout does not have
+		}
+		if !out.Set(k, r.sd) {
+			return SimDataMap{}, fmt.Errorf("legacy dataset key %d/%d/%d/%d is outside of the table", r.dealer, r.player, r.category, r.action)
+		}
+	}
+	for _, p := range pairs {
+		cell := out.Cell(Context{}, p.dealer, legacyPlayer(p.player, p.category), p.category)
+		if cell == nil || p.pair < 0 || p.pair >= PairSlots {
+			return SimDataMap{}, fmt.Errorf("legacy paired differences for %d/%d/%d are outside of the layout", p.dealer, p.player, p.category)
+		}
+		cell.Diffs[p.pair] = p.diff
+	}
+	return out, nil
+}
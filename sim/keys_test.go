@@ -0,0 +1,80 @@
+package sim
+
+import (
+	"blackjack/game"
+	"testing"
+)
+
+func hand(ranks ...int) []game.Card {
+	cards := make([]game.Card, len(ranks))
+	for i, rank := range ranks {
+		cards[i] = game.Card{Rank: rank}
+	}
+	return cards
+}
+
+func TestStateOf(t *testing.T) {
+	tests := []struct {
+		name                    string
+		hand                    []game.Card
+		later                   bool
+		player, category, total int
+	}{
+		{"T,6", hand(10, 6), false, 16, 0, 16},
+		{"A,6", hand(1, 6), false, 17, 1, 17},
+		{"K,K", hand(13, 13), false, 10, 2, 20},
+		{"K,Q: mixed tens are no pair", hand(13, 12), false, 20, 0, 20},
+		{"A,A", hand(1, 1), false, 1, 2, 12},
+		{"5,5", hand(5, 5), false, 5, 2, 10},
+		{"2,3,A", hand(2, 3, 1), true, 16, 1, 16},
+		{"A,6,5: the ace counts 1", hand(1, 6, 5), true, 12, 0, 12},
+		{"4,4,4: no pair after a hit", hand(4, 4, 4), true, 12, 0, 12},
+	}
+	for _, tt := range tests {
+		ctx, player, category := StateOf(tt.hand)
+		if ctx != (Context{Later: tt.later}) || player != tt.player || category != tt.category {
+			t.Errorf("%s: %s %d/%d, want later %v %d/%d", tt.name, ctx, player, category, tt.later, tt.player, tt.category)
+		}
+		if got := KeyTotal(player, category); got != tt.total {
+			t.Errorf("%s: KeyTotal = %d, want %d", tt.name, got, tt.total)
+		}
+	}
+}
+
+// the legacy baseline dataset keys soft hands by their score with the ace as 1
+// and pairs by their total
+func TestLegacyKeys(t *testing.T) {
+	sdm, err := LoadDataset("../bj_sim_data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := sdm.Metadata(); meta.Known() || meta.KeyVersion != 0 {
+		t.Errorf("legacy dataset loaded with metadata %+v", meta)
+	}
+	tests := []struct {
+		name                          string
+		dealer, player, category, act int
+		ev                            float64
+		trials                        int
+	}{
+		{"10 v A stand", 1, 10, 0, ActionStand, -0.6258317, 5204235},
+		{"A,9 v A stand (legacy soft 10)", 1, 20, 1, ActionStand, 0.3543024, 2021328},
+		{"5,5 v A split (legacy pair 10)", 1, 5, 2, ActionSplit, -0.56266314, 462915},
+	}
+	for _, tt := range tests {
+		sd, ok := sdm.Get(Key{Dealer: tt.dealer, Player: tt.player, Category: tt.category, Action: tt.act})
+		if !ok || sd.ExpectedValue != tt.ev || sd.Trials != tt.trials {
+			t.Errorf("%s: %+v (%v), want EV %g over %d trials", tt.name, sd, ok, tt.ev, tt.trials)
+		}
+	}
+
+	// a record outside of the layout is an error unless it is empty
+	records := []legacyRecord{{1, 30, 0, ActionStand, SimData{}}}
+	if _, err := fromLegacy(Metadata{}, records, nil); err != nil {
+		t.Errorf("empty record outside of the layout: %v", err)
+	}
+	records[0].sd.Trials = 1
+	if _, err := fromLegacy(Metadata{}, records, nil); err == nil {
+		t.Errorf("record outside of the layout: no error")
+	}
+}
@@ -0,0 +1,198 @@
+package sim
+
+import (
+	"blackjack/game"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// EngineVersion - bump when a change to the engine alters the values it records
+const EngineVersion = "1.0"
+
+// KeyVersion - bump when the meaning of the dataset keys changes (0: legacy keys,
+// see fromLegacy; 1: post-split hands filed with the original hands)
+const KeyVersion = 2
+
+// GitCommit of the build, set with -ldflags "-X blackjack/sim.GitCommit=..."
+// (defaults to the revision go build stamps into the binary)
+var GitCommit = ""
+
+// dataset sources
+const (
+	SourceSimulation = "simulation"
+	SourceExact      = "exact"
+	SourceInfinite   = "infinite"
+)
+
+// Metadata - how a dataset was produced. Datasets saved before metadata was
+// recorded load with the zero value (empty Source).
+type Metadata struct {
+	Source       string // SourceSimulation, SourceExact or SourceInfinite
+	Rules        game.Rules
+	InfiniteDeck bool // cards drawn with 1/13 probabilities
+
+	Seed  int64 // seed of the latest simulation session
+	Hands int   // hands simulated into the dataset over all sessions
+
+	Started  time.Time     // start of the first session
+	Finished time.Time     // latest save
+	WallTime time.Duration // summed over all sessions
+
+	EngineVersion string
+	GitCommit     string
+	KeyVersion    int
+
+	// counted simulations: shoes dealt over many rounds, decisions filed by true count
+	Count       string  // counting system (see counting.Lookup), "" when not counted
+	CountRange  int     // true count buckets -CountRange .. CountRange
+	Penetration float64 // share of the shoe dealt before the shuffle
+}
+
+// NewMetadata returns the metadata for a dataset produced now by this build
+func NewMetadata(source string, rules game.Rules) Metadata {
+	return Metadata{
+		Source:        source,
+		Rules:         rules,
+		Started:       time.Now(),
+		EngineVersion: EngineVersion,
+		GitCommit:     buildCommit(),
+		KeyVersion:    KeyVersion,
+	}
+}
+
+// SimulationMetadata - metadata of a simulation with the engine's rules
+func SimulationMetadata(infinite bool) Metadata {
+	m := NewMetadata(SourceSimulation, game.DefaultRules())
+	m.InfiniteDeck = infinite
+	return m
+}
+
+func buildCommit() string {
+	if GitCommit != "" {
+		return GitCommit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	revision, modified := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// Known reports whether the metadata was recorded (false for legacy datasets)
+func (m Metadata) Known() bool {
+	return m.Source != ""
+}
+
+// SameRules returns an error describing how the two datasets were produced
+// differently (source, rules or deck), nil if they can be combined
+func (m Metadata) SameRules(o Metadata) error {
+	if m.Source != o.Source {
+		return fmt.Errorf("%s dataset vs %s dataset", m.describeSource(), o.describeSource())
+	}
+	if m.Rules != o.Rules || m.InfiniteDeck != o.InfiniteDeck {
+		return fmt.Errorf("rules %s vs %s", m.describeRules(), o.describeRules())
+	}
+	if m.KeyVersion != o.KeyVersion {
+		return fmt.Errorf("state keys version %d vs %d", m.KeyVersion, o.KeyVersion)
+	}
+	if m.Count != o.Count || m.CountRange != o.CountRange || m.Penetration != o.Penetration {
+		return fmt.Errorf("count %s vs %s", m.describeCount(), o.describeCount())
+	}
+	return nil
+}
+
+func (m Metadata) describeSource() string {
+	if !m.Known() {
+		return "unknown"
+	}
+	return m.Source
+}
+
+func (m Metadata) describeCount() string {
+	if m.Count == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%s tc ±%d, %.0f%% penetration", m.Count, m.CountRange, m.Penetration*100)
+}
+
+func (m Metadata) describeRules() string {
+	s := m.Rules.String()
+	if m.InfiniteDeck {
+		s += " infinite deck"
+	}
+	return s
+}
+
+// merge combines the metadata of datasets merged into one
+func (m *Metadata) merge(o Metadata) {
+	if !m.Known() {
+		*m = o
+		return
+	}
+	m.Hands += o.Hands
+	m.WallTime += o.WallTime
+	if !o.Started.IsZero() && (m.Started.IsZero() || o.Started.Before(m.Started)) {
+		m.Started = o.Started
+	}
+	if o.Finished.After(m.Finished) {
+		m.Finished = o.Finished
+	}
+	if m.Seed != o.Seed {
+		m.Seed = 0 // several seeds
+	}
+}
+
+// String - one line summary e.g. "simulation, 1D S17 DAS HSA 1:1, 10000000 hands, ..."
+func (m Metadata) String() string {
+	if !m.Known() {
+		return "no metadata (legacy dataset, legacy keys)"
+	}
+	parts := []string{m.Source, m.describeRules()}
+	if m.Count != "" {
+		parts = append(parts, "counted "+m.describeCount())
+	}
+	if m.Source == SourceSimulation {
+		parts = append(parts, fmt.Sprintf("%d hands", m.Hands))
+		if m.Seed != 0 {
+			parts = append(parts, fmt.Sprintf("seed %d", m.Seed))
+		}
+	}
+	if !m.Finished.IsZero() {
+		parts = append(parts, "saved "+m.Finished.Format("2006-01-02 15:04:05"))
+	}
+	parts = append(parts, fmt.Sprintf("wall time %s", m.WallTime.Round(time.Second)))
+	version := "engine " + m.EngineVersion
+	if m.GitCommit != "" {
+		version += " @ " + m.GitCommit
+	}
+	parts = append(parts, version)
+	switch {
+	case m.KeyVersion == 0:
+		parts = append(parts, "legacy keys")
+	case m.KeyVersion < KeyVersion:
+		parts = append(parts, fmt.Sprintf("state keys v%d", m.KeyVersion))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Metadata returns the dataset's metadata for reading or updating
+func (sdm SimDataMap) Metadata() *Metadata {
+	if sdm.t == nil {
+		return &Metadata{}
+	}
+	return &sdm.t.meta
+}
@@ -0,0 +1,65 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSameRules(t *testing.T) {
+	base := SimulationMetadata(false)
+	tests := []struct {
+		name   string
+		change func(m *Metadata)
+		err    string // "" when the datasets combine
+	}{
+		{"same run config", func(m *Metadata) { m.Seed, m.Hands = 9, 5 }, ""},
+		{"other source", func(m *Metadata) { m.Source = SourceExact }, "simulation dataset vs exact dataset"},
+		{"legacy", func(m *Metadata) { *m = Metadata{} }, "vs unknown dataset"},
+		{"other decks", func(m *Metadata) { m.Rules.Decks = 6 }, "rules 1D"},
+		{"infinite deck", func(m *Metadata) { m.InfiniteDeck = true }, "infinite deck"},
+		{"older keys", func(m *Metadata) { m.KeyVersion = 1 }, "state keys version"},
+		{"counted", func(m *Metadata) { m.Count, m.CountRange, m.Penetration = "hilo", 6, 0.75 }, "count none vs hilo"},
+	}
+	for _, tt := range tests {
+		other := base
+		tt.change(&other)
+		err := base.SameRules(other)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestMetadataMerge(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := SimulationMetadata(false)
+	a.Seed, a.Hands, a.WallTime, a.Started, a.Finished = 1, 100, time.Minute, start.Add(time.Hour), start.Add(2*time.Hour)
+	b := a
+	b.Seed, b.Hands, b.Started, b.Finished = 2, 50, start, start.Add(3*time.Hour)
+
+	var m Metadata
+	m.merge(a) // an empty dataset takes the metadata over
+	m.merge(b)
+	if m.Hands != 150 || m.WallTime != 2*time.Minute || !m.Started.Equal(start) || !m.Finished.Equal(b.Finished) || m.Seed != 0 {
+		t.Errorf("merged %+v", m)
+	}
+}
+
+func TestMetadataString(t *testing.T) {
+	if got := (Metadata{}).String(); !strings.Contains(got, "legacy") {
+		t.Errorf("legacy metadata: %q", got)
+	}
+	m := SimulationMetadata(false)
+	m.Seed, m.Hands = 42, 1000
+	m.Count, m.CountRange, m.Penetration = "hilo", 6, 0.75
+	got := m.String()
+	for _, part := range []string{"simulation", "1D S17", "counted hilo tc ±6, 75% penetration", "1000 hands", "seed 42", "engine " + EngineVersion} {
+		if !strings.Contains(got, part) {
+			t.Errorf("%q has no %q", got, part)
+		}
+	}
+}
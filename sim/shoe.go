@@ -0,0 +1,100 @@
+package sim
+
+import (
+	"blackjack/counting"
+	"blackjack/game"
+	"fmt"
+	"math/rand"
+)
+
+// MaxCountRange - largest true count range the table (and binary format) holds
+const MaxCountRange = 20
+
+// shoe - the cards a worker deals its rounds from. A counted run deals one shoe
+// over many rounds until the cut card and files every decision by the true count;
+// otherwise every round gets a freshly shuffled deck. The deck has no observer:
+// the explored branches of a round are not seen at the table, so the count moves
+// on by the cards of the path played (advance).
+type shoe struct {
+	decks    int
+	infinite bool
+
+	counted    bool
+	countRange int
+	cut        int // rounds start only before this card
+
+	deck  game.Deck
+	count counting.Counter // the cards dealt in earlier rounds
+}
+
+// newShoe returns the shoe of a dataset's simulation - decks and count from its metadata
+func newShoe(meta Metadata) (*shoe, error) {
+	s := &shoe{decks: meta.Rules.Decks, infinite: meta.InfiniteDeck}
+	if meta.Count == "" {
+		return s, nil
+	}
+	if meta.InfiniteDeck {
+		return nil, fmt.Errorf("an infinite deck cannot be counted")
+	}
+	system, err := counting.Lookup(meta.Count)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Penetration <= 0 || meta.Penetration > 1 {
+		return nil, fmt.Errorf("penetration %g is not within (0, 1]", meta.Penetration)
+	}
+	s.counted, s.countRange = true, meta.CountRange
+	s.count.System = system
+	s.cut = int(meta.Penetration * float64(s.decks*52))
+	return s, nil
+}
+
+// deal starts the next round, shuffling a new shoe once the cut card is reached
+func (s *shoe) deal(rng *rand.Rand) game.GameState {
+	switch {
+	case s.infinite:
+		return game.StartRound(game.NewDeck(game.InfiniteDeck, rng))
+	case !s.counted:
+		return game.StartRound(game.NewShoe(s.decks, rng))
+	}
+	if s.deck.Cards == nil || s.deck.Drawn >= s.cut {
+		s.deck = game.NewShoe(s.decks, rng)
+		s.count.Reset(len(s.deck.Cards))
+	}
+	return game.StartRound(s.deck)
+}
+
+// bucket returns the true count bucket of the decision in gs. The cards of
+// earlier rounds, the player's cards and the dealer upcard have been seen, the
+// hole card has not.
+func (s *shoe) bucket(gs *game.GameState) int {
+	if !s.counted {
+		return 0
+	}
+	count := s.count
+	for _, hand := range gs.PlayerHand {
+		for _, card := range hand {
+			count.Seen(card)
+		}
+	}
+	count.Seen(gs.DealerHand[0])
+	return counting.Bucket(count.True(), s.countRange)
+}
+
+// advance moves a counted shoe past the round, which dealt up to card drawn
+func (s *shoe) advance(drawn int) {
+	if !s.counted {
+		return
+	}
+	for i := s.deck.Drawn; i < drawn; i++ {
+		s.count.Seen(s.deck.Cards[i%len(s.deck.Cards)])
+	}
+	s.deck.Drawn = drawn
+}
+
+func describeCountRange(countRange int) string {
+	if countRange == 0 {
+		return "no true count buckets"
+	}
+	return fmt.Sprintf("true count buckets ±%d", countRange)
+}
@@ -0,0 +1,85 @@
+package sim
+
+import (
+	"blackjack/game"
+	"math/rand"
+	"testing"
+)
+
+func TestNewShoe(t *testing.T) {
+	counted := newTestDataset(6).Metadata()
+	tests := []struct {
+		name   string
+		change func(m *Metadata)
+		ok     bool
+	}{
+		{"counted", func(m *Metadata) {}, true},
+		{"not counted", func(m *Metadata) { m.Count = "" }, true},
+		{"infinite deck", func(m *Metadata) { m.InfiniteDeck = true }, false},
+		{"unknown system", func(m *Metadata) { m.Count = "nope" }, false},
+		{"no penetration", func(m *Metadata) { m.Penetration = 0 }, false},
+		{"beyond the shoe", func(m *Metadata) { m.Penetration = 1.5 }, false},
+	}
+	for _, tt := range tests {
+		meta := *counted
+		tt.change(&meta)
+		if _, err := newShoe(meta); tt.ok != (err == nil) {
+			t.Errorf("%s: error %v", tt.name, err)
+		}
+	}
+	s, _ := newShoe(*counted)
+	if !s.counted || s.cut != 78 || s.countRange != 6 {
+		t.Errorf("2 decks at 75%%: counted %v, cut card %d, range %d", s.counted, s.cut, s.countRange)
+	}
+}
+
+// a counted shoe deals rounds up to the cut card, counting every card played
+func TestShoeCutCard(t *testing.T) {
+	s, err := newShoe(*newTestDataset(6).Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	gs := s.deal(rng)
+	first := &s.deck.Cards[0]
+	for rounds := 1; &s.deck.Cards[0] == first; rounds++ {
+		if s.deck.Drawn >= s.cut {
+			t.Fatalf("round %d dealt after the cut card (%d of %d)", rounds, s.deck.Drawn, s.cut)
+		}
+		s.advance(gs.Deck.Drawn)
+		running := 0.0
+		for _, card := range s.deck.Cards[:s.deck.Drawn] {
+			running += s.count.System.Tag(card)
+		}
+		if s.count.Running != running || s.count.Dealt != s.deck.Drawn {
+			t.Fatalf("round %d: running count %g over %d cards, want %g over %d", rounds, s.count.Running, s.count.Dealt, running, s.deck.Drawn)
+		}
+		gs = s.deal(rng)
+	}
+	if s.count.Running != 0 || s.count.Dealt != 0 {
+		t.Errorf("new shoe starts at running count %g with %d cards dealt", s.count.Running, s.count.Dealt)
+	}
+}
+
+// the bucket counts the player's cards and the upcard, not the hole card
+func TestShoeBucket(t *testing.T) {
+	meta := *newTestDataset(6).Metadata()
+	meta.Rules.Decks = 1
+	s, _ := newShoe(meta)
+	s.count.Reset(52)
+	tests := []struct {
+		cards []int
+		want  int
+	}{
+		{[]int{10, 10, 10, 2}, -4},  // -3 over 49 cards: -3.18
+		{[]int{10, 10, 10, 10}, -4}, // the hole card does not count
+		{[]int{2, 3, 4, 10}, 3},
+		{[]int{2, 7, 9, 10}, 1},
+	}
+	for _, tt := range tests {
+		gs := game.StartRound(game.Deck{Cards: hand(append(tt.cards, 5, 5)...)})
+		if got := s.bucket(&gs); got != tt.want {
+			t.Errorf("%v: bucket %d, want %d", tt.cards, got, tt.want)
+		}
+	}
+}
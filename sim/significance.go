@@ -0,0 +1,92 @@
+package sim
+
+import "math"
+
+// DefaultAlpha - significance level below which a cell's best action counts as resolved
+const DefaultAlpha = 0.05
+
+// Significance - how sure a cell's best action beats the runner-up
+type Significance struct {
+	Best     int
+	RunnerUp int     // -1 when only one action has trials
+	Margin   float64 // EV gap between best and runner-up
+	StdErr   float64 // standard error of the gap
+	PValue   float64 // one sided p-value of "best does not beat the runner-up"
+	Paired   bool    // from paired differences on the same deals (else independent errors)
+	Known    bool    // false when the cell is not reached or has too few trials
+}
+
+// Resolved reports whether the best action beats the runner-up at level alpha
+func (s Significance) Resolved(alpha float64) bool {
+	return s.Known && s.PValue < alpha
+}
+
+// Significance compares the best action of the cell with the runner-up. It uses
+// the paired differences recorded from the same deals when there are any, which
+// cancel the shared luck of the deal; datasets without them fall back to the
+// independent standard errors of the two actions.
+func (c *Cell) Significance() Significance {
+	best, ok := c.Best()
+	if !ok {
+		return Significance{RunnerUp: -1}
+	}
+	runnerUp := -1
+	for action, sd := range c.Data {
+		if action == best || !c.Has(action) || sd.Trials == 0 {
+			continue
+		}
+		if runnerUp < 0 || sd.ExpectedValue > c.Data[runnerUp].ExpectedValue {
+			runnerUp = action
+		}
+	}
+	if runnerUp < 0 {
+		return Significance{Best: best, RunnerUp: -1, Known: true} // nothing to choose between
+	}
+	return c.Versus(best, runnerUp)
+}
+
+// Versus tests whether action best beats action other in the cell, the same way
+// Significance tests the best action against the runner-up. Margin is negative
+// when other has the higher EV.
+func (c *Cell) Versus(best, other int) Significance {
+	s := Significance{Best: best, RunnerUp: other}
+	if best == other || c.Data[best].Trials == 0 || c.Data[other].Trials == 0 {
+		return s
+	}
+	s.Margin = c.Data[best].ExpectedValue - c.Data[other].ExpectedValue
+
+	a, b := best, other
+	if a > b {
+		a, b = b, a
+	}
+	gap := s.Margin
+	diff := c.Diffs[PairIndex(a, b)]
+	if v, ok := diff.Variance(); ok {
+		s.Paired = true
+		s.StdErr = math.Sqrt(v / float64(diff.VarTrials))
+		gap = diff.ExpectedValue // mean of value(a) - value(b)
+		if best != a {
+			gap = -gap
+		}
+	} else {
+		seBest, okBest := c.Data[best].StdErr()
+		seOther, okOther := c.Data[other].StdErr()
+		if !okBest || !okOther {
+			return s
+		}
+		s.StdErr = math.Sqrt(seBest*seBest + seOther*seOther)
+	}
+
+	s.Known = true
+	switch {
+	case s.StdErr > 0:
+		s.PValue = 0.5 * math.Erfc(gap/s.StdErr/math.Sqrt2)
+	case gap > 0:
+		s.PValue = 0
+	case gap < 0:
+		s.PValue = 1
+	default:
+		s.PValue = 0.5 // identical values - a tie
+	}
+	return s
+}
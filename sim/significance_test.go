@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+// record returns a record of trials trials with mean ev and standard error se
+func record(ev, se float64, trials int) SimData {
+	return SimData{ExpectedValue: ev, Trials: trials, VarTrials: trials,
+		M2: se * se * float64(trials) * float64(trials-1)}
+}
+
+func TestVersus(t *testing.T) {
+	const z95 = 1.6448536269514722 // one sided 5% quantile of the normal distribution
+	tests := []struct {
+		name       string
+		stand, hit SimData
+		diff       SimData // paired value(stand) - value(hit)
+		known      bool
+		paired     bool
+		margin, p  float64
+		resolved05 bool
+	}{
+		{"no trials", SimData{}, record(-0.5, 0.01, 100), SimData{}, false, false, 0, 0, false},
+		{"independent, 5% level", record(-0.4, 0.03, 100), record(-0.4-z95*0.05, 0.04, 100), SimData{},
+			true, false, z95 * 0.05, 0.05, false},
+		{"paired differences cancel the deal", record(-0.40, 0.1, 100), record(-0.41, 0.1, 100), record(0.01, 0.001, 100),
+			true, true, 0.01, 0.5 * math.Erfc(10/math.Sqrt2), true},
+		{"paired, no gap", record(-0.4, 0.1, 100), record(-0.4, 0.1, 100), record(0, 0.01, 100),
+			true, true, 0, 0.5, false},
+		{"no spread", SimData{ExpectedValue: 1, Trials: 2, VarTrials: 2}, SimData{ExpectedValue: 0, Trials: 2, VarTrials: 2}, SimData{},
+			true, false, 1, 0, true},
+	}
+	for _, tt := range tests {
+		c := Cell{Layout: 0b11}
+		c.Data[ActionStand], c.Data[ActionHit] = tt.stand, tt.hit
+		c.Diffs[PairIndex(ActionStand, ActionHit)] = tt.diff
+
+		s := c.Versus(ActionStand, ActionHit)
+		if s.Known != tt.known || s.Paired != tt.paired {
+			t.Errorf("%s: known %v paired %v, want %v %v", tt.name, s.Known, s.Paired, tt.known, tt.paired)
+			continue
+		}
+		if math.Abs(s.Margin-tt.margin) > 1e-12 || math.Abs(s.PValue-tt.p) > 1e-9 {
+			t.Errorf("%s: margin %v p %v, want %v %v", tt.name, s.Margin, s.PValue, tt.margin, tt.p)
+		}
+		if s.Resolved(DefaultAlpha) != tt.resolved05 {
+			t.Errorf("%s: resolved %v at %v", tt.name, s.Resolved(DefaultAlpha), DefaultAlpha)
+		}
+	}
+}
+
+func TestSignificanceRunnerUp(t *testing.T) {
+	c := Cell{Layout: 0b1111}
+	c.Data[ActionStand] = record(-0.2, 0.01, 100)
+	c.Data[ActionHit] = record(-0.1, 0.01, 100)
+	c.Data[ActionDouble] = record(-0.3, 0.01, 100)
+	if s := c.Significance(); s.Best != ActionHit || s.RunnerUp != ActionStand || !s.Known {
+		t.Errorf("best %d runner-up %d known %v, want hit, stand, true", s.Best, s.RunnerUp, s.Known)
+	}
+
+	// a single action has nothing to be resolved against
+	c = Cell{Layout: 0b1}
+	c.Data[ActionStand] = record(-0.2, 0.01, 100)
+	if s := c.Significance(); s.RunnerUp != -1 || !s.Known {
+		t.Errorf("single action: runner-up %d known %v", s.RunnerUp, s.Known)
+	}
+}
@@ -2,6 +2,7 @@ package sim
 
 import (
 	"blackjack/config"
+	"context"
 	"blackjack/game"
 	"fmt"
 	"time"
@@ -9,83 +10,319 @@ import (
 
 // individual simulation data piece
 type SimEvalData struct {
+	Context       Context // first or later decision, before or after a split
 	DealerStart   int
 	DealerScore   int
-	PlayerScores  int
-	PlayerHandCats int // 0: no ace, 1: has ace, 2: split available
+	PlayerScores  int // player key: hard total, soft total or pair rank (see StateOf)
+	PlayerHandCats int // 0: hard, 1: soft, 2: pair
 	ChoosenAction int // 0: hit, 1: stand, 2: double down, 3: split
 	Value float32 // resulting value of the action
 	Depth int // depth of the action in the game tree (for debugging)
 }
 
+// values of every action played from one decision node - the actions share the
+// deal, so their differences are paired samples
+type SimNodeData struct {
+	Context        Context
+	DealerStart    int
+	PlayerScores   int
+	PlayerHandCats int
+	Played         uint8      // bit per action explored from the node
+	Values         [4]float32 // value per action (PlayerActions order)
+}
+
+// insurance side bet against a dealer ace - half a bet paying 2:1 on a ten in the hole
+type SimInsuranceData struct {
+	Count int     // true count bucket when insurance is offered
+	Value float32 // +1 when the hole card is a ten, else -0.5 (per unit of the main bet)
+}
+
 type SimState struct {
 	SimEvalData []SimEvalData // list of all simulation data
+	Nodes       []SimNodeData // one entry per decision node
+	Insurance   []SimInsuranceData
+
+	shoe  *shoe // shoe the round is dealt from (true count of each decision)
+	drawn int   // cards of the shoe dealt by the end of the round, following the best actions
 }
 
 
-func SimulateBJ(hands int, dataset SimDataMap) {
+// hands simulated between progress reports / saves
+const progressInterval = 1_000_000
+
+// SimulateBJ simulates hands into dataset. The run stops early (after flushing the
+// dataset and a checkpoint) when ctx is cancelled - e.g. a time budget or SIGINT.
+// A dataset produced under other rules (or by a solver) is refused.
+func SimulateBJ(ctx context.Context, hands int, dataset SimDataMap) error {
+	meta := dataset.Metadata()
+	current := SimulationMetadata(config.AppConfig.InfiniteDeck)
+	current.Rules.Decks = config.AppConfig.Decks
+	if config.AppConfig.Count != "" {
+		current.Count = config.AppConfig.Count
+		current.CountRange = config.AppConfig.CountRange
+		current.Penetration = config.AppConfig.Penetration
+		if current.CountRange < 1 || current.CountRange > MaxCountRange {
+			return fmt.Errorf("true count range %d is not within 1 to %d", current.CountRange, MaxCountRange)
+		}
+	}
+	if _, err := newShoe(current); err != nil {
+		return err
+	}
+	if dataset.CountRange() != current.CountRange {
+		return fmt.Errorf("dataset has %s, the simulation %s: simulate into a new dataset (-in / -out)",
+			describeCountRange(dataset.CountRange()), describeCountRange(current.CountRange))
+	}
+	if meta.Known() {
+		if err := meta.SameRules(current); err != nil {
+			return fmt.Errorf("cannot continue a dataset produced under different rules: %v", err)
+		}
+		if meta.EngineVersion != EngineVersion {
+			fmt.Printf("Warning: dataset was recorded by engine %s, continuing with engine %s\n", meta.EngineVersion, EngineVersion)
+		}
+		meta.EngineVersion, meta.GitCommit = current.EngineVersion, current.GitCommit
+	} else {
+		if dataset.TotalTrials() > 0 {
+			return fmt.Errorf("cannot continue a dataset without metadata: its records use the legacy state keys, " +
+				"which mix first and later decisions - simulate into a new dataset (-in / -out)")
+		}
+		*meta = current
+	}
+
 	// run many simulations of the game
-	startTime := time.Now()
-	fmt.Printf("Starting simulation of %d million hands at %s...\n", hands / 1_000_000, startTime.Format("15:04:05"))
+	run := &Checkpoint{
+		HandsTarget:  hands,
+		OutFile:      config.AppConfig.OutFile,
+		Seed:         simSeed(),
+		Workers:      config.AppConfig.Workers,
+		InfiniteDeck: config.AppConfig.InfiniteDeck,
+		Decks:        current.Rules.Decks,
+		Count:        current.Count,
+		CountRange:   current.CountRange,
+		Penetration:  current.Penetration,
+		Convergence: Convergence{
+			Confidence: config.AppConfig.Confidence,
+			StdErr:     config.AppConfig.StdErrTarget,
+		},
+	}
+	meta.Seed = run.Seed
+	simulate(ctx, run, newWorkers(run.Workers, run.Seed), dataset)
+	return nil
+}
 
-	debugMode := config.IsDebugMode()
+// ResumeBJ continues the run saved in a checkpoint file from the hand it stopped at
+func ResumeBJ(ctx context.Context, filename string) error {
+	run, err := LoadCheckpoint(filename)
+	if err != nil {
+		return err
+	}
+	if len(run.RNGState) != run.Workers {
+		return fmt.Errorf("checkpoint %s has %d generator states for %d workers", filename, len(run.RNGState), run.Workers)
+	}
 
-	for i := 1; i <= hands; i++ {
+	if run.OutFile == "" {
+		return fmt.Errorf("checkpoint %s does not record its run config (written by an older version) - continue the dataset with -in instead", filename)
+	}
+	// the dataset must be the one saved with the checkpoint
+	dataset, err := LoadDataset(run.OutFile)
+	if err != nil {
+		return fmt.Errorf("cannot load the dataset of checkpoint %s: %v", filename, err)
+	}
+	meta := dataset.Metadata()
+	if meta.Hands != run.DatasetHands || dataset.TotalTrials() != run.DatasetTrials {
+		return fmt.Errorf("%s has %d hands (%d trials), checkpoint %s was saved with %d (%d trials): the dataset changed since",
+			run.OutFile, meta.Hands, dataset.TotalTrials(), filename, run.DatasetHands, run.DatasetTrials)
+	}
+	if meta.Rules.Decks != run.Decks || meta.Count != run.Count || dataset.CountRange() != run.CountRange {
+		return fmt.Errorf("checkpoint %s does not match its dataset (%s)", filename, meta)
+	}
+	// flags given now must agree with the run - a resume cannot change it
+	for _, f := range []struct {
+		name      string
+		same      bool
+		run, flag interface{}
+	}{
+		{"out", config.AppConfig.OutFile == run.OutFile, run.OutFile, config.AppConfig.OutFile},
+		{"infinite", config.AppConfig.InfiniteDeck == run.InfiniteDeck, run.InfiniteDeck, config.AppConfig.InfiniteDeck},
+		{"decks", config.AppConfig.Decks == run.Decks, run.Decks, config.AppConfig.Decks},
+		{"count", config.AppConfig.Count == run.Count, run.Count, config.AppConfig.Count},
+		{"tc-range", config.AppConfig.CountRange == run.CountRange, run.CountRange, config.AppConfig.CountRange},
+		{"penetration", config.AppConfig.Penetration == run.Penetration, run.Penetration, config.AppConfig.Penetration},
+		{"workers", config.AppConfig.Workers == run.Workers, run.Workers, config.AppConfig.Workers},
+	} {
+		if config.IsSet(f.name) && !f.same {
+			return fmt.Errorf("-%s %v does not match the checkpointed run (%v) - resume without it", f.name, f.flag, f.run)
+		}
+	}
 
-		recentSimStates := single_player_sim(&dataset)
+	// restore the run config, generators and shoes exactly as they were
+	config.AppConfig.OutFile = run.OutFile
+	config.AppConfig.InfiniteDeck = run.InfiniteDeck
+	config.AppConfig.Decks = run.Decks
+	config.AppConfig.Count = run.Count
+	config.AppConfig.CountRange = run.CountRange
+	config.AppConfig.Penetration = run.Penetration
+	workers := newWorkers(run.Workers, run.Seed)
+	for i, w := range workers {
+		w.src.State = run.RNGState[i]
+	}
+	if run.Count != "" {
+		if len(run.Shoes) != run.Workers {
+			return fmt.Errorf("checkpoint %s has %d shoes for %d workers", filename, len(run.Shoes), run.Workers)
+		}
+		for i, w := range workers {
+			s, err := newShoe(*meta)
+			if err != nil {
+				return err
+			}
+			saved, system := run.Shoes[i], s.count.System
+			s.deck.Cards, s.deck.Drawn = saved.Cards, saved.Drawn
+			s.count = saved.Count
+			s.count.System = system
+			w.shoe = s
+		}
+	}
 
-		//fmt.Println("Adding data to simulation data structure...")
-		dataset.AddData(recentSimStates)
+	fmt.Printf("Resuming from %s: %d of %d hands completed\n", filename, run.HandsCompleted, run.HandsTarget)
+	simulate(ctx, run, workers, dataset)
+	return nil
+}
 
-		if debugMode {
-		for _, d := range recentSimStates.SimEvalData {
-			fmt.Printf("DSS: %d, DS: %d, S: %d, cat: %d, Act: %d, V: %f\n",
-				d.DealerStart, d.DealerScore, d.PlayerScores, d.PlayerHandCats, d.ChoosenAction, d.Value)
-				if d.ChoosenAction == -1 {
-					println("")
-				}
+// simulate runs the hands remaining in run, saving the dataset and a checkpoint
+// after every progress interval and when ctx stops the run
+func simulate(ctx context.Context, run *Checkpoint, workers []*simWorker, dataset SimDataMap) {
+	startTime := time.Now()
+	hands := run.HandsTarget
+	startDone := run.HandsCompleted
+	fmt.Printf("Starting simulation of %d million hands on %d workers at %s...\n",
+		(hands - startDone) / 1_000_000, len(workers), startTime.Format("15:04:05"))
+
+	// metadata totals over all sessions
+	meta := dataset.Metadata()
+	baseHands, baseWall := meta.Hands, meta.WallTime
+
+	save := func(done int) {
+		meta.Hands = baseHands + done - startDone
+		meta.WallTime = baseWall + time.Since(startTime)
+		meta.Finished = time.Now()
+
+		//fmt.Println("Saving simulation data to", config.AppConfig.OutFile)
+		if err := dataset.Save(config.AppConfig.OutFile, config.AppConfig.Snapshots); err != nil {
+			fmt.Println("Error writing dataset:", err)
+		}
+		run.update(done, workers, dataset)
+		if err := run.Save(config.AppConfig.CheckpointFile); err != nil {
+			fmt.Println("Error writing checkpoint:", err)
+		}
+	}
+
+	// workers read the dataset (best action lookups) while recording into their
+	// own shard - shards are merged into the dataset once every worker is done
+	// with the batch, so the dataset is never written concurrently
+	done := startDone
+	converged := false
+	dropped := 0 // hands of a cancelled batch
+	for done < hands {
+		// batches end on multiples of the interval (also after a resume)
+		batch := progressInterval - done%progressInterval
+		if hands - done < batch {
+			batch = hands - done
+		}
+		// a cancelled batch ends early: it is dropped, the generators and shoes
+		// have moved on from the last save and a resume would not repeat it
+		if n := runBatch(ctx, workers, batch, dataset); n < batch {
+			dropped = n
+			break
+		}
+		done += batch
+		for _, w := range workers {
+			dataset.absorb(w.shard)
+			w.shard.clear()
+		}
+
+		// stop once every reachable cell is resolved
+		if run.Convergence.Enabled() && (done%progressInterval == 0 || done == hands) {
+			unresolved, reachable := run.Convergence.Unresolved(dataset)
+			fmt.Printf("Unresolved cells: %d of %d\n", unresolved, reachable)
+			if unresolved == 0 {
+				converged = true
+				save(done)
+				break
 			}
-		} 
-		if i%1_000_000 == 0 {
+		}
+
+		if done%progressInterval == 0 || done == hands {
 				elapsed := time.Since(startTime)
-				handsPerSecond := float64(i) / elapsed.Seconds()
-				progress := float64(i) / float64(hands) * 100
-				estTimeRemaining := float64(hands - i) / handsPerSecond 
+				handsPerSecond := float64(done - startDone) / elapsed.Seconds()
+				progress := float64(done) / float64(hands) * 100
+				estTimeRemaining := float64(hands - done) / handsPerSecond 
 
 				fmt.Printf("Progress: %.2f%% \n", progress)
 				fmt.Printf("Simulated %d million hands (%.2f hands/sec, elapsed: %s)\n", 
-					i/1_000_000, handsPerSecond, elapsed.Round(time.Second))
+					done/1_000_000, handsPerSecond, elapsed.Round(time.Second))
 				fmt.Printf("Est. time remaining: %.2f seconds\n\n", estTimeRemaining)
 
-
-				//fmt.Println("Saving simulation data to bj_sim_data.json...")
-				dataset.ToJSON()
+				save(done)
 			
 		}
-	
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// ---- final summary ----
+	reason := "completed"
+	switch {
+	case converged:
+		reason = "converged"
+	case ctx.Err() == context.DeadlineExceeded:
+		reason = "time budget reached"
+	case ctx.Err() == context.Canceled:
+		reason = "interrupted"
 	}
 	
 	totalElapsed := time.Since(startTime)
-	finalRate := float64(hands) / totalElapsed.Seconds()
-	fmt.Printf("Simulation completed! Total time: %s (%.2f hands/sec)\n", 
-		totalElapsed.Round(time.Millisecond), finalRate)
+	finalRate := float64(done - startDone) / totalElapsed.Seconds()
+	fmt.Printf("Simulation %s! Total time: %s (%.2f hands/sec)\n", 
+		reason, totalElapsed.Round(time.Millisecond), finalRate)
+	fmt.Printf("Hands this session: %d  Total: %d of %d (%.2f%%)\n",
+		done - startDone, done, hands, float64(done) / float64(hands) * 100)
+	dataset.PrintStdErrSummary()
+	if dropped > 0 {
+		fmt.Printf("Dropped the %d hands of the cancelled batch, so a resumed run matches an uninterrupted one\n", dropped)
+	}
+	if done < hands && !converged {
+		if done > 0 {
+			fmt.Printf("Dataset and checkpoint saved - continue with -resume (%s)\n", config.AppConfig.CheckpointFile)
+		} else {
+			fmt.Println("Stopped before the first batch completed - nothing was saved")
+		}
+	}
 }
 
-func single_player_sim(dataset *SimDataMap) SimState {
-	// run a single simulation of the game
+func single_player_sim(gs game.GameState, dataset *SimDataMap, s *shoe) SimState {
+	// run a single simulation of the game dealt in gs
 	// return the result of the game
 
-	gs := game.StartGame()
 	if config.IsDebugMode() {
 		gs.Print()
 	}	
 
 	simState := SimState{
 		SimEvalData: make([]SimEvalData, 0),
+		shoe:        s,
+	}
+
+	// insurance against a dealer ace - decided on the first two cards
+	if gs.DealerShownScore == 1 {
+		insurance := SimInsuranceData{Count: s.bucket(&gs), Value: -0.5}
+		if gs.DealerHand[1].Rank >= 10 {
+			insurance.Value = 1
+		}
+		simState.Insurance = append(simState.Insurance, insurance)
 	}
 
 	// Start recursive exploration from initial game state
-	node_explore(gs, &simState, dataset)
+	_, simState.drawn = node_explore(gs, &simState, dataset)
 	
 	if config.IsDebugMode() {
 	fmt.Println("Simulation complete.")
@@ -94,6 +331,15 @@ func single_player_sim(dataset *SimDataMap) SimState {
 }
 
 
+// action indices - last key of SimDataMap (see ActionSlots)
+const (
+	ActionStand = iota
+	ActionHit
+	ActionDouble
+	ActionSplit
+	ActionSurrender // only produced by the exact solver
+)
+
 // Try all possible actions for current hand
 // Stand (action 0) - always available
 var PlayerActions = []struct {
@@ -107,9 +353,10 @@ var PlayerActions = []struct {
 }
 
 // ! I have rewritten this but not working properly...
-func node_explore(gs game.GameState, simState *SimState, dataset *SimDataMap) (value float32) {
+func node_explore(gs game.GameState, simState *SimState, dataset *SimDataMap) (value float32, drawn int) {
 	// for any given hand state, explore all possible actions recursively
 	// returns the value of the final outcome...
+	// and the cards dealt by the end of the round following the best actions
 
 	if gs.HandToPlay >= len(gs.PlayerHand) {
 		// !GAME OVER - will exit here
@@ -130,20 +377,28 @@ func node_explore(gs game.GameState, simState *SimState, dataset *SimDataMap) (v
 			}
 			total += float32(v)
 		}
-		return total
+		return total, gs.Deck.Drawn
 		
 	}
 	// Get current hand's legal moves
 	currentHandMoves := gs.PlayerMoves[gs.HandToPlay]
 
-	// Get hand category once before the loop
-	hand_cat := getHandCategory(gs.PlayerHand[gs.HandToPlay])
+	// Get the hand's dataset key once before the loop
+	hand_ctx, hand_key, hand_cat := HandState(&gs)
+	hand_ctx.Count = simState.shoe.bucket(&gs)
 
 	// ! MAIN LOOP
 	if config.IsDebugMode() {
 		fmt.Println("new loop  ",len(simState.SimEvalData))
 	}
 	actions_vals := make(map[int]float32) // map of action index to value
+	actions_drawn := make(map[int]int)    // map of action index to cards dealt by the end
+	node := SimNodeData{
+		Context:        hand_ctx,
+		DealerStart:    gs.DealerShownScore,
+		PlayerScores:   hand_key,
+		PlayerHandCats: hand_cat,
+	}
 	for i, action := range PlayerActions {
 		// do all actions...
 
@@ -159,24 +414,29 @@ func node_explore(gs game.GameState, simState *SimState, dataset *SimDataMap) (v
 			}
 
 			// Continue exploring from this state
-			value = node_explore(gsCopy, simState, dataset)
+			value, drawn = node_explore(gsCopy, simState, dataset)
 
 			simData := SimEvalData{
+				Context:        hand_ctx,
 				DealerStart:    gs.DealerShownScore,
 				DealerScore:    gs.DealerScore,
-				PlayerScores:   gs.PlayerScore[gs.HandToPlay],
+				PlayerScores:   hand_key,
 				PlayerHandCats: hand_cat,
 				ChoosenAction:  action.actionInt,
 				Value:          value,  // Will be updated when game finishes
 			}
 			simState.SimEvalData = append(simState.SimEvalData, simData)
+			node.Played |= 1 << action.actionInt
+			node.Values[action.actionInt] = value
+			actions_drawn[i] = drawn
 
 		} else {
-			// Action not possible, skip
+			// Action not possible, skip (no cards dealt - never the previous action's)
 			value = -100
 		}
 		actions_vals[i] = value
 	}
+	simState.Nodes = append(simState.Nodes, node)
 	if config.IsDebugMode() {
 		fmt.Printf("<> FIN All Act  %d\n", gs.PlayerScore[gs.HandToPlay])
 	}
@@ -186,19 +446,15 @@ func node_explore(gs game.GameState, simState *SimState, dataset *SimDataMap) (v
 
 	// Find the best action based on expected values from the dataset
 	var best_action int = 0  // default to stand
-	var best_expected_value float32 = -1000
+	var best_expected_value float64 = -1000
 	
 	// Check if we have data for this state in our dataset
-	if dealerMap, ok := (*dataset)[gs.DealerShownScore]; ok {
-		if playerMap, ok := dealerMap[gs.PlayerScore[gs.HandToPlay]]; ok {
-			if categoryMap, ok := playerMap[hand_cat]; ok {
-				// Find action with highest expected value
-				for action, simData := range categoryMap {
-					if simData.Trials > 0 && simData.ExpectedValue > best_expected_value {
-						best_expected_value = simData.ExpectedValue
-						best_action = action
-					}
-				}
+	if cell := dataset.Cell(hand_ctx, gs.DealerShownScore, hand_key, hand_cat); cell != nil {
+		// Find action with highest expected value
+		for action, simData := range cell.Data {
+			if cell.Has(action) && simData.Trials > 0 && simData.ExpectedValue > best_expected_value {
+				best_expected_value = simData.ExpectedValue
+				best_action = action
 			}
 		}
 	}
@@ -211,33 +467,5 @@ func node_explore(gs game.GameState, simState *SimState, dataset *SimDataMap) (v
 	}
 
 
-	return final_val
+	return final_val, actions_drawn[best_action]
 }
-
-// Helper function to categorize player hand
-func getHandCategory(hand []game.Card) int {
-	// 0: no ace, 1: has ace, 2: split available
-	hasAce := false
-	canSplit := false
-	rank_sum := 0
-	
-	for _, card := range hand {
-		if card.Rank == 1 {
-			hasAce = true
-		}
-		rank_sum += card.Rank
-	}
-
-	// Check if split is available (two cards of same rank) 
-	if len(hand) == 2 && hand[0].Rank == hand[1].Rank {
-		canSplit = true
-	}
-	
-	if canSplit {
-		return 2
-	} else if hasAce && rank_sum <= 11 {
-		return 1
-	} else {
-		return 0
-	}
-}
\ No newline at end of file
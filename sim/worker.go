@@ -0,0 +1,110 @@
+package sim
+
+import (
+	"blackjack/config"
+	"blackjack/game"
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// simWorker - one simulation goroutine with its own generator and dataset shard
+type simWorker struct {
+	rng   *rand.Rand
+	src   *game.RandSource
+	shard SimDataMap // records since the last merge, cleared once absorbed
+	shoe  *shoe      // dealt on from batch to batch
+}
+
+// simSeed returns the configured seed, or a time based one
+func simSeed() int64 {
+	if config.AppConfig.Seed != 0 {
+		return config.AppConfig.Seed
+	}
+	return time.Now().UnixNano()
+}
+
+func newWorkers(n int, seed int64) []*simWorker {
+	// shards and shoes are set up by the first batch (see runBatch)
+	if n < 1 {
+		n = 1
+	}
+	workers := make([]*simWorker, n)
+	for i := range workers {
+		// spread the worker seeds so their streams do not overlap
+		rng, src := game.NewRand(seed + int64(i)*0x5DEECE66D)
+		workers[i] = &simWorker{
+			rng: rng,
+			src: src,
+		}
+	}
+	return workers
+}
+
+// runBatch simulates hands split evenly over the workers, each recording into its
+// own empty shard. dataset is only read while the batch runs. Returns the number
+// of hands completed - fewer than requested if ctx is cancelled.
+func runBatch(ctx context.Context, workers []*simWorker, hands int, dataset SimDataMap) int {
+	completed := make([]int, len(workers))
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		n := hands / len(workers)
+		if i < hands%len(workers) {
+			n++
+		}
+
+		// a worker keeps its shard and its shoe for the whole run: a counted
+		// shoe is dealt on to the cut card across batches
+		if w.shard.IsZero() {
+			w.shard = CreateCountedStructure(dataset.CountRange())
+		}
+		if w.shoe == nil {
+			s, err := newShoe(*dataset.Metadata())
+			if err != nil {
+				panic("Error: " + err.Error()) // checked by SimulateBJ
+			}
+			w.shoe = s
+		}
+		wg.Add(1)
+		go func(i int, w *simWorker, n int) {
+			defer wg.Done()
+			completed[i] = w.run(ctx, n, dataset)
+		}(i, w, n)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, n := range completed {
+		total += n
+	}
+	return total
+}
+
+func (w *simWorker) run(ctx context.Context, hands int, dataset SimDataMap) int {
+	debugMode := config.IsDebugMode()
+
+	for i := 0; i < hands; i++ {
+		select {
+		case <-ctx.Done():
+			return i
+		default:
+		}
+
+		gs := w.shoe.deal(w.rng)
+		recentSimStates := single_player_sim(gs, &dataset, w.shoe)
+
+		//fmt.Println("Adding data to simulation data structure...")
+		w.shard.AddData(recentSimStates)
+		w.shoe.advance(recentSimStates.drawn)
+
+		if debugMode {
+			for _, d := range recentSimStates.SimEvalData {
+				fmt.Printf("DSS: %d, DS: %d, S: %d, cat: %d, Act: %d, V: %f\n",
+					d.DealerStart, d.DealerScore, d.PlayerScores, d.PlayerHandCats, d.ChoosenAction, d.Value)
+			}
+		}
+	}
+	return hands
+}
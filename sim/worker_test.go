@@ -0,0 +1,79 @@
+package sim
+
+import (
+	"context"
+	"testing"
+)
+
+// newTestDataset returns an empty dataset of a single deck simulation
+func newTestDataset(countRange int) SimDataMap {
+	sdm := CreateCountedStructure(countRange)
+	meta := sdm.Metadata()
+	*meta = SimulationMetadata(false)
+	if countRange > 0 {
+		meta.Rules.Decks = 2
+		meta.Count, meta.CountRange, meta.Penetration = "hilo", countRange, 0.75
+	}
+	return sdm
+}
+
+func TestRunBatch(t *testing.T) {
+	dataset := newTestDataset(0)
+	workers := newWorkers(3, 7)
+	if n := runBatch(context.Background(), workers, 100, dataset); n != 100 {
+		t.Fatalf("%d hands completed, want 100", n)
+	}
+	if dataset.TotalTrials() != 0 {
+		t.Errorf("the dataset was written during the batch")
+	}
+	trials := 0
+	for i, w := range workers {
+		if w.shard.TotalTrials() == 0 {
+			t.Errorf("worker %d recorded nothing", i)
+		}
+		trials += w.shard.TotalTrials()
+		dataset.absorb(w.shard)
+	}
+	if dataset.TotalTrials() != trials {
+		t.Errorf("%d trials absorbed from shards of %d", dataset.TotalTrials(), trials)
+	}
+	// workers draw from their own streams
+	if w := workers; w[0].src.State == w[1].src.State {
+		t.Errorf("workers 0 and 1 share a generator state")
+	}
+}
+
+// the same seed and worker count record the same trials, batch after batch
+func TestRunBatchRepeats(t *testing.T) {
+	for _, countRange := range []int{0, 2} {
+		run := func() SimDataMap {
+			dataset := newTestDataset(countRange)
+			workers := newWorkers(2, 11)
+			for batch := 0; batch < 3; batch++ {
+				runBatch(context.Background(), workers, 40, dataset)
+				for _, w := range workers {
+					dataset.absorb(w.shard)
+					w.shard.clear()
+				}
+			}
+			return dataset
+		}
+		sameRecords(t, run(), run())
+	}
+}
+
+// a cancelled context stops the workers before their next hand
+func TestRunBatchCancelled(t *testing.T) {
+	dataset := newTestDataset(0)
+	workers := newWorkers(2, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n := runBatch(ctx, workers, 1000, dataset); n != 0 {
+		t.Errorf("%d hands completed after the cancel", n)
+	}
+	for i, w := range workers {
+		if w.shard.TotalTrials() != 0 {
+			t.Errorf("worker %d recorded %d trials after the cancel", i, w.shard.TotalTrials())
+		}
+	}
+}
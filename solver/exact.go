@@ -0,0 +1,492 @@
+/*
+Exact composition dependent solver
+
+Rather than sampling random decks (see sim.node_explore) the solver recurses over
+the remaining shoe composition, weighting every card by its probability of being
+drawn. For each dealer upcard every player hand composition that fits into the
+dataset (raw player score <= 20) is evaluated for stand, hit, double, split and
+surrender.
+
+Results are folded into the same SimDataMap layout the simulator uses:
+each cell is the frequency weighted mean over the hand compositions that map onto
+it, and Trials holds the number of compositions aggregated into the cell.
+
+Notes:
+  - split EVs assume the pair is split once (no resplitting) and the two hands do
+    not affect each other's draws
+  - with DealerPeek every EV is conditional on the dealer not holding blackjack
+*/
+package solver
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Shoe composition
+
+// shoe holds the number of cards left per rank value (index 1: Ace .. 10: ten valued)
+type shoe [11]int
+
+// shoeKey is a compact comparable form of a shoe - used to memoise results
+type shoeKey [10]uint8
+
+func newShoe(decks int) shoe {
+	var s shoe
+	for r := 1; r <= 9; r++ {
+		s[r] = 4 * decks
+	}
+	s[10] = 16 * decks // 10, J, Q, K
+	return s
+}
+
+func (s *shoe) count() int {
+	n := 0
+	for r := 1; r <= 10; r++ {
+		n += s[r]
+	}
+	return n
+}
+
+func (s *shoe) key() shoeKey {
+	var k shoeKey
+	for r := 1; r <= 10; r++ {
+		k[r-1] = uint8(s[r])
+	}
+	return k
+}
+
+// minus returns the shoe with the cards of hand removed
+func (s shoe) minus(hand *shoe) shoe {
+	for r := 1; r <= 10; r++ {
+		s[r] -= hand[r]
+	}
+	return s
+}
+
+// best total of a hand given its raw score (aces as 1) and whether it holds an ace
+func bestTotal(raw int, ace bool) int {
+	if ace && raw <= 11 {
+		return raw + 10
+	}
+	return raw
+}
+
+// ============================================================================
+// Dealer outcome probabilities
+
+// dealerDist holds the probability of each final dealer outcome
+// 0-4: dealer stands on 17-21, 5: bust, 6: natural
+type dealerDist [7]float64
+
+const (
+	dealerBust    = 5
+	dealerNatural = 6
+)
+
+// upSolver evaluates every player hand against one dealer upcard
+type upSolver struct {
+	rules game.Rules
+	up    int
+
+	dealerMemo map[shoeKey]dealerDist
+}
+
+// dealer returns the dealer outcome distribution for the remaining shoe s
+func (us *upSolver) dealer(s *shoe) dealerDist {
+	key := s.key()
+	if d, ok := us.dealerMemo[key]; ok {
+		return d
+	}
+
+	// with peek the hole card can not complete a blackjack
+	exclude := 0
+	if us.rules.DealerPeek {
+		if us.up == 1 {
+			exclude = 10
+		} else if us.up == 10 {
+			exclude = 1
+		}
+	}
+
+	var d dealerDist
+	us.dealerDraw(s, us.up, us.up == 1, 1, 1.0, exclude, &d)
+
+	// renormalise - excluded hole cards can leave the total below 1
+	total := 0.0
+	for _, p := range d {
+		total += p
+	}
+	if total > 0 {
+		for i := range d {
+			d[i] /= total
+		}
+	}
+
+	us.dealerMemo[key] = d
+	return d
+}
+
+func (us *upSolver) dealerDraw(s *shoe, raw int, ace bool, cards int, prob float64, exclude int, d *dealerDist) {
+	best := bestTotal(raw, ace)
+
+	switch {
+	case raw > 21:
+		d[dealerBust] += prob
+		return
+	case cards == 2 && best == 21:
+		d[dealerNatural] += prob
+		return
+	case best > 17 || (best == 17 && !(us.rules.DealerHitsSoft17 && ace && raw == 7)):
+		d[best-17] += prob
+		return
+	}
+
+	n := s.count()
+	if exclude != 0 {
+		n -= s[exclude]
+	}
+	if n <= 0 {
+		// shoe exhausted - can not happen with a realistic deck count
+		d[dealerBust] += prob
+		return
+	}
+
+	for r := 1; r <= 10; r++ {
+		if s[r] == 0 || r == exclude {
+			continue
+		}
+		p := float64(s[r]) / float64(n)
+		s[r]--
+		us.dealerDraw(s, raw+r, ace || r == 1, cards+1, prob*p, 0, d)
+		s[r]++
+	}
+}
+
+// standEV returns the value of standing on best against the dealer distribution d
+func standEV(best int, natural bool, d dealerDist, blackjackPays float64) float64 {
+	if best > 21 {
+		return -1
+	}
+	if natural {
+		return (1 - d[dealerNatural]) * blackjackPays // push against a dealer natural
+	}
+
+	ev := d[dealerBust] - d[dealerNatural]
+	for i := 0; i < 5; i++ {
+		dealerScore := 17 + i
+		if best > dealerScore {
+			ev += d[i]
+		} else if best < dealerScore {
+			ev -= d[i]
+		}
+	}
+	return ev
+}
+
+// ============================================================================
+// Player hand evaluation
+
+// handEV holds the optimal values reachable from a player hand composition
+type handEV struct {
+	stand float64
+	hit   float64 // hit then play on optimally
+}
+
+func (h handEV) best() float64 {
+	if h.hit > h.stand {
+		return h.hit
+	}
+	return h.stand
+}
+
+// handCtx evaluates hands drawn from base (the shoe less the cards that are
+// known before the hand starts)
+type handCtx struct {
+	us    *upSolver
+	base  shoe
+	split bool // post split hand - no blackjack, double only with DAS
+
+	memo map[shoeKey]handEV
+}
+
+func (us *upSolver) newCtx(base shoe, split bool) *handCtx {
+	return &handCtx{
+		us:    us,
+		base:  base,
+		split: split,
+		memo:  make(map[shoeKey]handEV),
+	}
+}
+
+// eval returns the stand / hit values for the hand composition
+func (c *handCtx) eval(hand *shoe, raw int, cards int) handEV {
+	key := hand.key()
+	if v, ok := c.memo[key]; ok {
+		return v
+	}
+
+	ace := hand[1] > 0
+	best := bestTotal(raw, ace)
+	remaining := c.base.minus(hand)
+
+	var v handEV
+	natural := !c.split && cards == 2 && best == 21
+	v.stand = standEV(best, natural, c.us.dealer(&remaining), c.us.rules.BlackjackPays)
+
+	n := remaining.count()
+	for r := 1; r <= 10; r++ {
+		if remaining[r] == 0 {
+			continue
+		}
+		p := float64(remaining[r]) / float64(n)
+		if raw+r > 21 {
+			v.hit -= p
+			continue
+		}
+		hand[r]++
+		v.hit += p * c.eval(hand, raw+r, cards+1).best()
+		hand[r]--
+	}
+
+	c.memo[key] = v
+	return v
+}
+
+// double returns the value of doubling the hand (one card then stand)
+func (c *handCtx) double(hand *shoe, raw int) float64 {
+	remaining := c.base.minus(hand)
+	n := remaining.count()
+
+	ev := 0.0
+	for r := 1; r <= 10; r++ {
+		if remaining[r] == 0 {
+			continue
+		}
+		p := float64(remaining[r]) / float64(n)
+		if raw+r > 21 {
+			ev -= p
+			continue
+		}
+		hand[r]++
+		ev += p * c.eval(hand, raw+r, 3).stand
+		hand[r]--
+	}
+	return 2 * ev
+}
+
+// splitPair returns the value of splitting a pair of rank x (both hands combined)
+func (c *handCtx) splitPair(x int) float64 {
+	var other shoe
+	other[x] = 1
+	sc := c.us.newCtx(c.base.minus(&other), true)
+
+	var hand shoe
+	hand[x] = 1
+	remaining := sc.base.minus(&hand)
+	n := remaining.count()
+
+	ev := 0.0
+	for r := 1; r <= 10; r++ {
+		if remaining[r] == 0 {
+			continue
+		}
+		p := float64(remaining[r]) / float64(n)
+		hand[r]++
+
+		v := sc.eval(&hand, x+r, 2)
+		best := v.stand
+		if x != 1 || c.us.rules.HitSplitAces {
+			best = v.best()
+			if c.us.rules.DoubleAfterSplit {
+				if d := sc.double(&hand, x+r); d > best {
+					best = d
+				}
+			}
+		}
+		ev += p * best
+
+		hand[r]--
+	}
+	return 2 * ev
+}
+
+// ============================================================================
+// Aggregation into SimDataMap
+
+// cellAcc accumulates the weighted EVs of one dataset cell
+type cellAcc struct {
+	sum    float64
+	weight float64
+	count  int
+}
+
+type cellKey struct {
+	later                    bool // three or more cards (sim.Context)
+	player, category, action int
+}
+
+// solveUp evaluates every player composition against one upcard
+func solveUp(rules game.Rules, up int) map[cellKey]*cellAcc {
+	us := &upSolver{
+		rules:      rules,
+		up:         up,
+		dealerMemo: make(map[shoeKey]dealerDist),
+	}
+
+	full := newShoe(rules.Decks)
+	full[up]--
+	ctx := us.newCtx(full, false)
+	splits := make(map[int]float64) // split value per pair rank
+
+	acc := make(map[cellKey]*cellAcc)
+	add := func(later bool, player, category, action int, w, ev float64) {
+		k := cellKey{later, player, category, action}
+		a, ok := acc[k]
+		if !ok {
+			a = &cellAcc{}
+			acc[k] = a
+		}
+		a.sum += w * ev
+		a.weight += w
+		a.count++
+	}
+
+	var hand shoe
+	visit := func(cards, raw int, pair bool, w float64) {
+		// dataset key (see sim.StateOf)
+		player, category := raw, 0
+		if pair {
+			player, category = raw/2, 2
+		} else if hand[1] > 0 && raw <= 11 {
+			player, category = raw+10, 1
+		}
+		later := cards > 2
+
+		v := ctx.eval(&hand, raw, cards)
+		add(later, player, category, sim.ActionStand, w, v.stand)
+		add(later, player, category, sim.ActionHit, w, v.hit)
+
+		if cards != 2 {
+			return
+		}
+		add(later, player, category, sim.ActionDouble, w, ctx.double(&hand, raw))
+		if rules.Surrender {
+			add(later, player, category, sim.ActionSurrender, w, -0.5)
+		}
+		if pair {
+			x := raw / 2
+			if _, ok := splits[x]; !ok {
+				splits[x] = ctx.splitPair(x)
+			}
+			add(later, player, category, sim.ActionSplit, w, splits[x])
+		}
+	}
+	sameTens := sameRankTens(rules.Decks, up)
+	ctx.enumerate(&hand, 1, 0, 0, func(cards, raw int, w float64) {
+		pair := cards == 2 && hand[raw/2] == 2 && raw%2 == 0
+		if pair && raw == 20 {
+			// the shoe counts tens by value: only tens of the same rank are a
+			// pair, mixed ones (10-K) are a hard 20 as in the simulator
+			visit(cards, raw, false, w*(1-sameTens))
+			w *= sameTens
+		}
+		visit(cards, raw, pair, w)
+	})
+
+	return acc
+}
+
+// sameRankTens is the probability that two tens drawn from the shoe (less the
+// upcard) have the same rank, e.g. (4d-1)/(16d-1) for d decks and a non-ten up
+func sameRankTens(decks, up int) float64 {
+	perRank := 4 * decks
+	tens := 4 * perRank
+	same := 4 * perRank * (perRank - 1)
+	if up == 10 {
+		// one rank is short the upcard
+		tens--
+		same -= 2 * (perRank - 1)
+	}
+	return float64(same) / float64(tens*(tens-1))
+}
+
+// enumerate visits every hand composition (2+ cards, raw score <= 20) once
+// along with how often the simulator would reach it (summed over draw orders)
+func (c *handCtx) enumerate(hand *shoe, from, cards, raw int, visit func(cards, raw int, w float64)) {
+	if cards >= 2 {
+		visit(cards, raw, c.frequency(hand, cards))
+	}
+	for r := from; r <= 10 && raw+r <= 20; r++ {
+		if hand[r] >= c.base[r] {
+			continue
+		}
+		hand[r]++
+		c.enumerate(hand, r, cards+1, raw+r, visit)
+		hand[r]--
+	}
+}
+
+// frequency is the probability of drawing the composition in any order
+func (c *handCtx) frequency(hand *shoe, cards int) float64 {
+	// number of distinct draw orders: cards! / prod(k_r!)
+	// times the probability of one specific order
+	p := 1.0
+	n := c.base.count()
+	for i := 0; i < cards; i++ {
+		p *= float64(i+1) / float64(n-i)
+	}
+	for r := 1; r <= 10; r++ {
+		for k := 0; k < hand[r]; k++ {
+			p *= float64(c.base[r]-k) / float64(k+1)
+		}
+	}
+	return p
+}
+
+// SolveExact computes composition dependent EVs for every player hand against
+// every dealer upcard under the given rules
+func SolveExact(rules game.Rules) (sim.SimDataMap, error) {
+	if rules.Decks < 1 || rules.Decks > 15 {
+		return sim.SimDataMap{}, fmt.Errorf("exact solver supports 1 to 15 decks, got %d", rules.Decks)
+	}
+
+	startTime := time.Now()
+
+	// every upcard is independent - solve them side by side
+	results := make([]map[cellKey]*cellAcc, 11)
+	var wg sync.WaitGroup
+	for up := 1; up <= 10; up++ {
+		wg.Add(1)
+		go func(up int) {
+			defer wg.Done()
+			results[up] = solveUp(rules, up)
+		}(up)
+	}
+	wg.Wait()
+
+	sdm := sim.CreateSimDataStructure()
+	meta := sdm.Metadata()
+	*meta = sim.NewMetadata(sim.SourceExact, rules)
+	meta.Started = startTime
+	meta.Finished = time.Now()
+	meta.WallTime = meta.Finished.Sub(startTime)
+
+	for up := 1; up <= 10; up++ {
+		for k, a := range results[up] {
+			ctx := sim.Context{Later: k.later}
+			if sdm.Cell(ctx, up, k.player, k.category) == nil {
+				continue // outside of the dataset layout
+			}
+			sdm.Set(sim.Key{Context: ctx, Dealer: up, Player: k.player, Category: k.category, Action: k.action}, sim.SimData{
+				ExpectedValue: a.sum / a.weight,
+				Trials:        a.count,
+			})
+		}
+	}
+	return sdm, nil
+}
@@ -0,0 +1,131 @@
+package solver
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"math"
+	"testing"
+)
+
+// rules without peek, so the dealer distributions below are unconditional
+func testRules(decks int) game.Rules {
+	r := game.DefaultRules()
+	r.Decks = decks
+	return r
+}
+
+// stand EVs of two card hands against the shoe they are dealt from, worked out
+// independently by enumerating the dealer's draws from the remaining cards
+func TestExactStandEV(t *testing.T) {
+	tests := []struct {
+		decks, up int
+		hand      []int
+		want      float64
+	}{
+		{1, 10, []int{10, 6}, -0.580261906574},
+		{1, 6, []int{10, 6}, -0.179022898802},
+		{1, 10, []int{9, 7}, -0.557378291209},
+		{2, 1, []int{10, 8}, -0.370209328805},
+	}
+	for _, tt := range tests {
+		us := &upSolver{rules: testRules(tt.decks), up: tt.up, dealerMemo: make(map[shoeKey]dealerDist)}
+		base := newShoe(tt.decks)
+		base[tt.up]--
+		ctx := us.newCtx(base, false)
+
+		var hand shoe
+		raw := 0
+		for _, c := range tt.hand {
+			hand[c]++
+			raw += c
+		}
+		got := ctx.eval(&hand, raw, len(tt.hand)).stand
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%dD %v v %d: stand EV %.12f, want %.12f", tt.decks, tt.hand, tt.up, got, tt.want)
+		}
+	}
+}
+
+func TestExactDealerSumsToOne(t *testing.T) {
+	for _, peek := range []bool{false, true} {
+		for up := 1; up <= 10; up++ {
+			rules := testRules(1)
+			rules.DealerPeek = peek
+			us := &upSolver{rules: rules, up: up, dealerMemo: make(map[shoeKey]dealerDist)}
+			s := newShoe(1)
+			s[up]--
+			total := 0.0
+			for _, p := range us.dealer(&s) {
+				total += p
+			}
+			if math.Abs(total-1) > 1e-12 {
+				t.Errorf("up %d, peek %v: dealer outcomes sum to %.15f", up, peek, total)
+			}
+		}
+	}
+}
+
+func TestSolveExact(t *testing.T) {
+	sdm, err := SolveExact(testRules(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sdm.Metadata().Source; got != sim.SourceExact {
+		t.Errorf("source %q, want %q", got, sim.SourceExact)
+	}
+
+	// hard 16 v T is T,6 and 9,7 weighted by how often they are dealt (60:16)
+	want := (60*-0.580261906574 + 16*-0.557378291209) / 76
+	sd, ok := sdm.Get(sim.Key{Dealer: 10, Player: 16, Category: 0, Action: sim.ActionStand})
+	if !ok {
+		t.Fatal("no record for stand on hard 16 v T")
+	}
+	if math.Abs(sd.ExpectedValue-want) > 1e-9 || sd.Trials != 2 {
+		t.Errorf("stand on hard 16 v T: EV %.12f over %d compositions, want %.12f over 2", sd.ExpectedValue, sd.Trials, want)
+	}
+
+	for _, decks := range []int{0, 16} {
+		if _, err := SolveExact(testRules(decks)); err == nil {
+			t.Errorf("%d decks: no error", decks)
+		}
+	}
+}
+
+func TestSameRankTens(t *testing.T) {
+	tests := []struct {
+		decks, up int
+		want      float64
+	}{
+		{1, 5, 3.0 / 15},
+		{1, 10, 42.0 / 210}, // 15 tens left, one rank short
+		{6, 2, 23.0 / 95},
+	}
+	for _, tt := range tests {
+		if got := sameRankTens(tt.decks, tt.up); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%dD up %d: %g, want %g", tt.decks, tt.up, got, tt.want)
+		}
+	}
+}
+
+// two tens are a pair only with the same rank: the mixed ones are the first
+// decision hard 20s, with the same stand EV and no split
+func TestExactMixedTens(t *testing.T) {
+	sdm, err := SolveExact(testRules(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for up := 1; up <= 10; up++ {
+		hard, ok := sdm.Get(sim.Key{Dealer: up, Player: 20, Category: 0, Action: sim.ActionStand})
+		if !ok || hard.Trials == 0 {
+			t.Errorf("up %d: no first decision hard 20", up)
+			continue
+		}
+		pair, ok := sdm.Get(sim.Key{Dealer: up, Player: 10, Category: 2, Action: sim.ActionStand})
+		if !ok || math.Abs(hard.ExpectedValue-pair.ExpectedValue) > 1e-12 {
+			t.Errorf("up %d: stand on hard 20 %.12f, on T,T %.12f", up, hard.ExpectedValue, pair.ExpectedValue)
+		}
+		if split, ok := sdm.Get(sim.Key{Dealer: up, Player: 20, Category: 0, Action: sim.ActionSplit}); ok && split.Trials > 0 {
+			t.Errorf("up %d: hard 20 has a split record", up)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+/*
+Infinite deck total dependent solver
+
+With an infinite deck every rank is drawn with probability 1/13 (ten valued 4/13)
+regardless of the cards already dealt, so a hand's value depends only on its total
+and whether it is soft. This allows basic strategy and the overall house edge to be
+solved by dynamic programming over totals - a theoretical baseline for the
+simulation dataset.
+
+Split EVs assume no resplitting.
+*/
+package solver
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"fmt"
+	"strings"
+)
+
+// probability of drawing each rank value (index 1: Ace .. 10: ten valued)
+var infiniteProb = [11]float64{0, 1.0 / 13, 1.0 / 13, 1.0 / 13, 1.0 / 13, 1.0 / 13, 1.0 / 13, 1.0 / 13, 1.0 / 13, 1.0 / 13, 4.0 / 13}
+
+// Decision holds the EV of every action for one strategy chart entry
+type Decision struct {
+	Action int        // best action (sim.ActionStand ...)
+	EV     [5]float64 // EV per action, indexed like the dataset
+	Legal  [5]bool    // whether the action is available
+}
+
+// InfiniteResult - basic strategy and house edge for an infinite deck
+type InfiniteResult struct {
+	Rules game.Rules
+
+	Hard  [22][11]Decision // [hard total][dealer up] - two card hands 5-20
+	Soft  [22][11]Decision // [soft total][dealer up] - A,2 (13) to A,9 (20)
+	Pairs [11][11]Decision // [pair rank][dealer up] - 1: aces .. 10: tens
+
+	PlayerEV  float64 // EV per initial hand
+	HouseEdge float64 // -PlayerEV
+}
+
+// infiniteUp holds the DP tables for one dealer upcard
+type infiniteUp struct {
+	rules  game.Rules
+	dealer dealerDist
+
+	// optimal value of continuing to play a hand (no double / split)
+	hard [32]float64
+	soft [22]float64
+}
+
+// infiniteDealer returns the dealer outcome distribution for an upcard
+func infiniteDealer(rules game.Rules, up int) dealerDist {
+	exclude := 0
+	if rules.DealerPeek {
+		if up == 1 {
+			exclude = 10
+		} else if up == 10 {
+			exclude = 1
+		}
+	}
+
+	var d dealerDist
+	var draw func(raw int, ace bool, cards int, prob float64)
+	draw = func(raw int, ace bool, cards int, prob float64) {
+		best := bestTotal(raw, ace)
+		switch {
+		case raw > 21:
+			d[dealerBust] += prob
+			return
+		case cards == 2 && best == 21:
+			d[dealerNatural] += prob
+			return
+		case best > 17 || (best == 17 && !(rules.DealerHitsSoft17 && ace && raw == 7)):
+			d[best-17] += prob
+			return
+		}
+
+		norm := 1.0
+		if cards == 1 && exclude != 0 {
+			norm -= infiniteProb[exclude]
+		}
+		for r := 1; r <= 10; r++ {
+			if cards == 1 && r == exclude {
+				continue
+			}
+			draw(raw+r, ace || r == 1, cards+1, prob*infiniteProb[r]/norm)
+		}
+	}
+	draw(up, up == 1, 1, 1.0)
+
+	return d
+}
+
+func newInfiniteUp(rules game.Rules, up int) *infiniteUp {
+	iu := &infiniteUp{
+		rules:  rules,
+		dealer: infiniteDealer(rules, up),
+	}
+
+	// hard 21..12 only ever draw into higher hard totals
+	for t := 21; t >= 12; t-- {
+		iu.hard[t] = maxFloat(iu.stand(t), iu.hitHard(t))
+	}
+	// soft totals draw into higher soft totals or hard 12+
+	for s := 21; s >= 12; s-- {
+		iu.soft[s] = maxFloat(iu.stand(s), iu.hitSoft(s))
+	}
+	// low hard totals may draw an ace into a soft total
+	for t := 11; t >= 2; t-- {
+		iu.hard[t] = maxFloat(iu.stand(t), iu.hitHard(t))
+	}
+	return iu
+}
+
+func (iu *infiniteUp) stand(total int) float64 {
+	return standEV(total, false, iu.dealer, iu.rules.BlackjackPays)
+}
+
+// next returns the continuation value after drawing r onto a hand
+func (iu *infiniteUp) next(total int, soft bool, r int) float64 {
+	total += r
+	if r == 1 && !soft && total+10 <= 21 {
+		return iu.soft[total+10]
+	}
+	if soft {
+		if total <= 21 {
+			return iu.soft[total]
+		}
+		total -= 10
+	}
+	if total > 21 {
+		return -1
+	}
+	return iu.hard[total]
+}
+
+func (iu *infiniteUp) hitHard(t int) float64 {
+	ev := 0.0
+	for r := 1; r <= 10; r++ {
+		ev += infiniteProb[r] * iu.next(t, false, r)
+	}
+	return ev
+}
+
+func (iu *infiniteUp) hitSoft(s int) float64 {
+	ev := 0.0
+	for r := 1; r <= 10; r++ {
+		ev += infiniteProb[r] * iu.next(s, true, r)
+	}
+	return ev
+}
+
+// double returns the value of doubling a hand (one card then stand)
+func (iu *infiniteUp) double(total int, soft bool) float64 {
+	ev := 0.0
+	for r := 1; r <= 10; r++ {
+		t := total + r
+		if r == 1 && !soft && t+10 <= 21 {
+			t += 10
+		} else if soft && t > 21 {
+			t -= 10
+		}
+		if t > 21 {
+			ev -= infiniteProb[r]
+		} else {
+			ev += infiniteProb[r] * iu.stand(t)
+		}
+	}
+	return 2 * ev
+}
+
+// twoCard returns the hand state (total, soft) of two cards
+func twoCard(a, b int) (int, bool) {
+	if a == 1 || b == 1 {
+		if a+b+10 <= 21 {
+			return a + b + 10, true
+		}
+	}
+	return a + b, false
+}
+
+// decide evaluates a two card hand
+func (iu *infiniteUp) decide(a, b int, split bool) Decision {
+	total, soft := twoCard(a, b)
+
+	var dec Decision
+	dec.Legal[sim.ActionStand] = true
+	dec.Legal[sim.ActionHit] = true
+	dec.Legal[sim.ActionDouble] = true
+	dec.EV[sim.ActionStand] = iu.stand(total)
+	if soft {
+		dec.EV[sim.ActionHit] = iu.hitSoft(total)
+	} else {
+		dec.EV[sim.ActionHit] = iu.hitHard(total)
+	}
+	dec.EV[sim.ActionDouble] = iu.double(total, soft)
+
+	if split {
+		// post split hand - DAS / split ace restrictions, no surrender
+		dec.Legal[sim.ActionDouble] = iu.rules.DoubleAfterSplit
+		if a == 1 && !iu.rules.HitSplitAces {
+			dec.Legal[sim.ActionHit] = false
+			dec.Legal[sim.ActionDouble] = false
+		}
+	} else {
+		if iu.rules.Surrender {
+			dec.Legal[sim.ActionSurrender] = true
+			dec.EV[sim.ActionSurrender] = -0.5
+		}
+		if a == b {
+			dec.Legal[sim.ActionSplit] = true
+			dec.EV[sim.ActionSplit] = iu.splitPair(a)
+		}
+	}
+
+	dec.Action = sim.ActionStand
+	for action := range dec.EV {
+		if dec.Legal[action] && dec.EV[action] > dec.EV[dec.Action] {
+			dec.Action = action
+		}
+	}
+	return dec
+}
+
+func (d Decision) best() float64 {
+	return d.EV[d.Action]
+}
+
+// withoutSplit drops the split option e.g. T,T used as a hard 20
+func (d Decision) withoutSplit() Decision {
+	d.Legal[sim.ActionSplit] = false
+	d.EV[sim.ActionSplit] = 0
+	d.Action = sim.ActionStand
+	for action := range d.EV {
+		if d.Legal[action] && d.EV[action] > d.EV[d.Action] {
+			d.Action = action
+		}
+	}
+	return d
+}
+
+// splitPair returns the value of splitting a pair of rank x (both hands combined)
+func (iu *infiniteUp) splitPair(x int) float64 {
+	ev := 0.0
+	for r := 1; r <= 10; r++ {
+		ev += infiniteProb[r] * iu.decide(x, r, true).best()
+	}
+	return 2 * ev
+}
+
+// SolveInfinite solves total dependent basic strategy and the house edge for an
+// infinite deck
+func SolveInfinite(rules game.Rules) InfiniteResult {
+	res := InfiniteResult{Rules: rules}
+
+	for up := 1; up <= 10; up++ {
+		iu := newInfiniteUp(rules, up)
+
+		// representative two card hands for the charts
+		for t := 5; t <= 20; t++ {
+			a := t - 2
+			if a > 10 {
+				a = 10
+			}
+			// total dependent - only the split option differs between compositions
+			res.Hard[t][up] = iu.decide(a, t-a, false).withoutSplit()
+		}
+		for s := 13; s <= 20; s++ {
+			res.Soft[s][up] = iu.decide(1, s-11, false)
+		}
+		for x := 1; x <= 10; x++ {
+			res.Pairs[x][up] = iu.decide(x, x, false)
+		}
+
+		// overall EV for this upcard
+		upEV := 0.0
+		for a := 1; a <= 10; a++ {
+			for b := 1; b <= 10; b++ {
+				p := infiniteProb[a] * infiniteProb[b]
+				total, _ := twoCard(a, b)
+				if total == 21 {
+					upEV += p * standEV(21, true, iu.dealer, rules.BlackjackPays)
+					continue
+				}
+				upEV += p * iu.decide(a, b, false).best()
+			}
+		}
+
+		// with peek the EVs above are conditional on no dealer blackjack
+		if rules.DealerPeek && (up == 1 || up == 10) {
+			pNatural := infiniteProb[10] // hole card completing the blackjack
+			if up == 10 {
+				pNatural = infiniteProb[1]
+			}
+			// player natural pushes, everything else loses the base bet
+			pPlayerNatural := 2 * infiniteProb[1] * infiniteProb[10]
+			upEV = pNatural*(-(1 - pPlayerNatural)) + (1-pNatural)*upEV
+		}
+
+		res.PlayerEV += infiniteProb[up] * upEV
+	}
+
+	res.HouseEdge = -res.PlayerEV
+	return res
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ToSimData folds the strategy charts into the SimDataMap layout so the results
+// can be compared against a simulation dataset (Trials is 1 for every solved cell)
+func (res InfiniteResult) ToSimData() sim.SimDataMap {
+	sdm := sim.CreateSimDataStructure()
+	meta := sdm.Metadata()
+	*meta = sim.NewMetadata(sim.SourceInfinite, res.Rules)
+	meta.InfiniteDeck = true
+	meta.Finished = meta.Started
+
+	set := func(up, player, category int, dec Decision) {
+		if sdm.Cell(sim.Context{}, up, player, category) == nil {
+			return
+		}
+		for action, legal := range dec.Legal {
+			if legal {
+				sdm.Set(sim.Key{Dealer: up, Player: player, Category: category, Action: action},
+					sim.SimData{ExpectedValue: dec.EV[action], Trials: 1})
+			}
+		}
+	}
+
+	for up := 1; up <= 10; up++ {
+		for t := 5; t <= 20; t++ {
+			set(up, t, 0, res.Hard[t][up])
+		}
+		for s := 13; s <= 20; s++ {
+			set(up, s, 1, res.Soft[s][up])
+		}
+		for x := 1; x <= 10; x++ {
+			set(up, x, 2, res.Pairs[x][up])
+		}
+	}
+	return sdm
+}
+
+// ============================================================================
+// Chart printing
+
+var actionCodes = [5]string{"S", "H", "D", "P", "R"}
+
+// Print the basic strategy charts and house edge
+func (res InfiniteResult) Print() {
+	fmt.Printf("Infinite deck basic strategy (%s)\n", res.Rules)
+
+	header := "       2  3  4  5  6  7  8  9  T  A"
+	row := func(label string, decs [11]Decision) {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("%-5s", label))
+		for _, up := range []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 1} {
+			sb.WriteString(fmt.Sprintf("  %s", actionCodes[decs[up].Action]))
+		}
+		fmt.Println(sb.String())
+	}
+
+	fmt.Println("\nHard" + header[4:])
+	for t := 5; t <= 20; t++ {
+		row(fmt.Sprint(t), res.Hard[t])
+	}
+	fmt.Println("\nSoft" + header[4:])
+	for s := 13; s <= 20; s++ {
+		row(fmt.Sprintf("A,%d", s-11), res.Soft[s])
+	}
+	fmt.Println("\nPair" + header[4:])
+	for x := 1; x <= 10; x++ {
+		label := fmt.Sprintf("%d,%d", x, x)
+		if x == 1 {
+			label = "A,A"
+		} else if x == 10 {
+			label = "T,T"
+		}
+		row(label, res.Pairs[x])
+	}
+
+	fmt.Printf("\nPlayer EV: %+.4f%%  (house edge %.4f%%)\n", res.PlayerEV*100, res.HouseEdge*100)
+}
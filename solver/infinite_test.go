@@ -0,0 +1,85 @@
+package solver
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"math"
+	"testing"
+)
+
+// S17 with a dealer peek: the published infinite deck EVs are conditional on
+// the dealer not holding blackjack
+func peekRules() game.Rules {
+	r := game.DefaultRules()
+	r.DealerPeek = true
+	r.BlackjackPays = 1.5
+	return r
+}
+
+func TestSolveInfiniteEV(t *testing.T) {
+	res := SolveInfinite(peekRules())
+	tests := []struct {
+		name   string
+		dec    Decision
+		action int
+		want   float64
+	}{
+		{"16 v T stand", res.Hard[16][10], sim.ActionStand, -0.540430333995},
+		{"16 v T hit", res.Hard[16][10], sim.ActionHit, -0.539826346281},
+		{"12 v T stand", res.Hard[12][10], sim.ActionStand, -0.540430333995},
+		{"12 v T hit", res.Hard[12][10], sim.ActionHit, -0.381042992848},
+		{"16 v 6 stand", res.Hard[16][6], sim.ActionStand, -0.153699015830},
+		{"16 v 6 hit", res.Hard[16][6], sim.ActionHit, -0.430926502729},
+	}
+	for _, tt := range tests {
+		if got := tt.dec.EV[tt.action]; math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: EV %.12f, want %.12f", tt.name, got, tt.want)
+		}
+	}
+
+	// 16 v T is the closest call of basic strategy: hitting wins by 0.0006
+	if got := res.Hard[16][10].Action; got != sim.ActionHit {
+		t.Errorf("16 v T: best action %s, want hit", sim.ActionNames[got])
+	}
+	if got := res.Hard[16][6].Action; got != sim.ActionStand {
+		t.Errorf("16 v 6: best action %s, want stand", sim.ActionNames[got])
+	}
+}
+
+func TestInfiniteDealerSumsToOne(t *testing.T) {
+	for _, h17 := range []bool{false, true} {
+		for _, peek := range []bool{false, true} {
+			rules := peekRules()
+			rules.DealerHitsSoft17, rules.DealerPeek = h17, peek
+			for up := 1; up <= 10; up++ {
+				total := 0.0
+				for _, p := range infiniteDealer(rules, up) {
+					total += p
+				}
+				if math.Abs(total-1) > 1e-12 {
+					t.Errorf("up %d, h17 %v, peek %v: dealer outcomes sum to %.15f", up, h17, peek, total)
+				}
+			}
+		}
+	}
+}
+
+// the exact solver converges on the infinite deck values as the shoe grows
+func TestExactApproachesInfinite(t *testing.T) {
+	rules := peekRules()
+	rules.Decks = 8
+	inf := SolveInfinite(rules)
+	exact, err := SolveExact(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, up := range []int{2, 6, 10} {
+		for _, action := range []int{sim.ActionStand, sim.ActionHit} {
+			sd, _ := exact.Get(sim.Key{Dealer: up, Player: 16, Category: 0, Action: action})
+			want := inf.Hard[16][up].EV[action]
+			if math.Abs(sd.ExpectedValue-want) > 0.01 {
+				t.Errorf("16 v %d %s: exact 8 deck EV %.4f, infinite deck %.4f", up, sim.ActionNames[action], sd.ExpectedValue, want)
+			}
+		}
+	}
+}
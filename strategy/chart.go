@@ -0,0 +1,348 @@
+/*
+Basic strategy charts
+
+A Chart holds the action to play for hard totals, soft totals and pairs against each
+dealer upcard. Charts are read and written in a plain text layout:
+
+	Hard   2  3  4  5  6  7  8  9  T  A
+	12     H  H  S  S  S  H  H  H  H  H
+	...
+	Soft   2  3  4  5  6  7  8  9  T  A
+	A,7    S  Ds Ds Ds Ds S  S  H  H  H
+	...
+	Pair   2  3  4  5  6  7  8  9  T  A
+	A,A    P  P  P  P  P  P  P  P  P  P
+
+Codes: S stand, H hit, D double, P split, R surrender. A second lower case letter
+gives the fallback when the action is not allowed (Ds: double else stand), the
+default fallback is hit.
+*/
+package strategy
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// actionLetters - chart letter per action index
+var actionLetters = [5]string{"S", "H", "D", "P", "R"}
+
+// dealer upcards in chart column order
+var chartUps = []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 1}
+
+// Code - chart entry: action to play and the fallback when it is not allowed
+type Code struct {
+	Action   int
+	Fallback int
+}
+
+func defaultFallback(action int) int {
+	if action == sim.ActionStand {
+		return sim.ActionStand
+	}
+	return sim.ActionHit
+}
+
+// NewCode returns a code with the default fallback
+func NewCode(action int) Code {
+	return Code{Action: action, Fallback: defaultFallback(action)}
+}
+
+func (c Code) String() string {
+	s := actionLetters[c.Action]
+	if c.Fallback != defaultFallback(c.Action) {
+		s += strings.ToLower(actionLetters[c.Fallback])
+	}
+	return s
+}
+
+// ParseCode reads a chart code such as "H" or "Ds"
+func ParseCode(s string) (Code, error) {
+	letter := func(l string) (int, error) {
+		for action, a := range actionLetters {
+			if strings.EqualFold(a, l) {
+				return action, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown action code %q", l)
+	}
+
+	if len(s) == 0 || len(s) > 2 {
+		return Code{}, fmt.Errorf("invalid chart code %q", s)
+	}
+	action, err := letter(s[:1])
+	if err != nil {
+		return Code{}, err
+	}
+	code := NewCode(action)
+	if len(s) == 2 {
+		if code.Fallback, err = letter(s[1:]); err != nil {
+			return Code{}, err
+		}
+	}
+	return code, nil
+}
+
+// ============================================================================
+// Chart structure
+
+// the rows read from a dataset - by FromDataset, Diff and Deviations alike
+const (
+	firstHard, lastHard = 5, 20
+	firstSoft, lastSoft = 13, 21 // a split hand dealt A,T is soft 21, not a blackjack
+)
+
+type Chart struct {
+	Name string
+
+	Hard  [22][11]Code // [hard total 4-21][dealer up 1-10]
+	Soft  [22][11]Code // [soft total 12-21][dealer up 1-10]
+	Pairs [11][11]Code // [pair rank 1: aces .. 10: tens][dealer up 1-10]
+}
+
+// NewChart returns a chart hitting below 17 (hard) / 18 (soft) and never splitting
+func NewChart(name string) Chart {
+	c := Chart{Name: name}
+	for up := 1; up <= 10; up++ {
+		for t := 4; t <= 21; t++ {
+			c.Hard[t][up] = NewCode(sim.ActionHit)
+			if t >= 17 {
+				c.Hard[t][up] = NewCode(sim.ActionStand)
+			}
+		}
+		for s := 12; s <= 21; s++ {
+			c.Soft[s][up] = NewCode(sim.ActionHit)
+			if s >= 18 {
+				c.Soft[s][up] = NewCode(sim.ActionStand)
+			}
+		}
+	}
+	c.fillPairs([11]bool{})
+	return c
+}
+
+// fillPairs sets the pair rows not in set to play the pair as a total
+func (c *Chart) fillPairs(set [11]bool) {
+	for x := 1; x <= 10; x++ {
+		if set[x] {
+			continue
+		}
+		for up := 1; up <= 10; up++ {
+			if x == 1 {
+				c.Pairs[x][up] = c.Soft[12][up]
+			} else {
+				c.Pairs[x][up] = c.Hard[2*x][up]
+			}
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// sim.Policy implementation
+
+// Choose returns the chart action for the hand being played, falling back when
+// the action is not legal. Doubling follows the chart only on two card hands.
+func (c Chart) Choose(gs *game.GameState) int {
+	ind := gs.HandToPlay
+	hand := gs.PlayerHand[ind]
+	moves := gs.PlayerMoves[ind]
+	up := gs.DealerShownScore
+
+	legal := func(action int) bool {
+		switch action {
+		case sim.ActionStand, sim.ActionHit:
+			return true
+		case sim.ActionDouble:
+			return len(hand) == 2 && moves&0b010 != 0
+		case sim.ActionSplit:
+			return moves&0b100 != 0
+		default:
+			return false // surrender is not offered by the engine
+		}
+	}
+
+	var code Code
+	total, soft := game.HandTotal(hand)
+	switch {
+	case legal(sim.ActionSplit):
+		code = c.Pairs[rankValue(hand[0])][up]
+	case total > 21:
+		code = NewCode(sim.ActionStand)
+	case soft:
+		code = c.Soft[total][up]
+	default:
+		code = c.Hard[total][up]
+	}
+
+	if legal(code.Action) {
+		return code.Action
+	}
+	if legal(code.Fallback) {
+		return code.Fallback
+	}
+	return sim.ActionHit
+}
+
+func rankValue(card game.Card) int {
+	if card.Rank > 10 {
+		return 10
+	}
+	return card.Rank
+}
+
+// ============================================================================
+// Reading / writing
+
+func upLabel(up int) string {
+	switch up {
+	case 1:
+		return "A"
+	case 10:
+		return "T"
+	default:
+		return strconv.Itoa(up)
+	}
+}
+
+func softLabel(s int) string {
+	return "A," + upLabel(s-11)
+}
+
+func pairLabel(x int) string {
+	return upLabel(x) + "," + upLabel(x)
+}
+
+// Write the chart in the plain text layout (readable by ParseChart)
+func (c Chart) Write(w io.Writer) error {
+	return c.Render(w, FormatText, nil, 0, 0)
+}
+
+// ParseChart reads a chart in the plain text layout. Rows that are not given keep
+// the NewChart defaults. Lines starting with # are comments (the first names the chart).
+func ParseChart(r io.Reader) (Chart, error) {
+	c := NewChart("")
+	var pairsSet [11]bool
+
+	section := ""
+	var columns []int // dealer up per column of the current section
+
+	cardValue := func(s string) (int, error) {
+		switch strings.ToUpper(s) {
+		case "A":
+			return 1, nil
+		case "T", "10":
+			return 10, nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 2 || v > 9 {
+			return 0, fmt.Errorf("invalid card %q", s)
+		}
+		return v, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if c.Name == "" {
+				c.Name = strings.TrimSpace(line[1:])
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch strings.ToLower(fields[0]) {
+		case "hard", "soft", "pair", "pairs":
+			section = strings.ToLower(fields[0])[:4]
+			columns = columns[:0]
+			for _, f := range fields[1:] {
+				up, err := cardValue(f)
+				if err != nil {
+					return c, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				columns = append(columns, up)
+			}
+			continue
+		}
+
+		if section == "" {
+			return c, fmt.Errorf("line %d: row before a Hard/Soft/Pair header", lineNo)
+		}
+		if len(fields)-1 != len(columns) {
+			return c, fmt.Errorf("line %d: expected %d codes, got %d", lineNo, len(columns), len(fields)-1)
+		}
+
+		// find the row this line sets
+		var target *[11]Code
+		label := strings.ToUpper(fields[0])
+		switch section {
+		case "hard":
+			t, err := strconv.Atoi(label)
+			if err != nil || t < 4 || t > 21 {
+				return c, fmt.Errorf("line %d: invalid hard total %q", lineNo, fields[0])
+			}
+			target = &c.Hard[t]
+		case "soft":
+			parts := strings.Split(label, ",")
+			if len(parts) != 2 || parts[0] != "A" {
+				return c, fmt.Errorf("line %d: invalid soft hand %q", lineNo, fields[0])
+			}
+			v, err := cardValue(parts[1])
+			if err != nil {
+				return c, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			target = &c.Soft[11+v]
+		case "pair":
+			parts := strings.Split(label, ",")
+			if len(parts) != 2 || parts[0] != parts[1] {
+				return c, fmt.Errorf("line %d: invalid pair %q", lineNo, fields[0])
+			}
+			v, err := cardValue(parts[0])
+			if err != nil {
+				return c, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			target = &c.Pairs[v]
+			pairsSet[v] = true
+		}
+
+		for i, f := range fields[1:] {
+			code, err := ParseCode(f)
+			if err != nil {
+				return c, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			target[columns[i]] = code
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c, err
+	}
+
+	c.fillPairs(pairsSet)
+	return c, nil
+}
+
+// LoadChart reads a chart file
+func LoadChart(filename string) (Chart, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Chart{}, err
+	}
+	defer file.Close()
+
+	c, err := ParseChart(file)
+	if err != nil {
+		return c, fmt.Errorf("%s: %w", filename, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,147 @@
+package strategy
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Code
+		err  bool
+	}{
+		{s: "H", want: Code{sim.ActionHit, sim.ActionHit}},
+		{s: "S", want: Code{sim.ActionStand, sim.ActionStand}},
+		{s: "Ds", want: Code{sim.ActionDouble, sim.ActionStand}},
+		{s: "d", want: Code{sim.ActionDouble, sim.ActionHit}},
+		{s: "Rs", want: Code{sim.ActionSurrender, sim.ActionStand}},
+		{s: "", err: true},
+		{s: "X", err: true},
+		{s: "Dsx", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCode(tt.s)
+		if tt.err != (err != nil) || !tt.err && got != tt.want {
+			t.Errorf("ParseCode(%q) = %+v, %v, want %+v", tt.s, got, err, tt.want)
+		}
+		if !tt.err && !strings.EqualFold(got.String(), tt.s) {
+			t.Errorf("%q prints as %q", tt.s, got)
+		}
+	}
+}
+
+// a chart written out parses back to the same chart
+func TestChartRoundTrip(t *testing.T) {
+	for _, name := range ReferenceNames() {
+		want, err := Reference(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := want.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseChart(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.Hard != want.Hard || got.Soft != want.Soft || got.Pairs != want.Pairs {
+			t.Errorf("%s: parsed back differently:\n%s", name, buf.String())
+		}
+	}
+}
+
+func TestParseChartErrors(t *testing.T) {
+	for _, text := range []string{
+		"12 H H H H H H H H H H", // row before a header
+		"Hard 2 3 4\n12 H H",     // too few codes
+		"Hard 2 3\n23 H H",       // no such total
+		"Soft 2 3\n7,A H H",      // soft hands start with the ace
+		"Pair 2 3\n8,9 P P",      // not a pair
+		"Hard 2 1 X\n12 H H H",   // no such upcard
+		"Hard 2 3\n12 H Q",       // no such action
+	} {
+		if _, err := ParseChart(strings.NewReader(text)); err == nil {
+			t.Errorf("%q: no error", text)
+		}
+	}
+}
+
+// stackedRound deals the player cards[0], cards[1], the dealer cards[2] up over
+// cards[3] and then the rest of the cards
+func stackedRound(cards ...int) game.GameState {
+	var deck game.Deck
+	for _, rank := range cards {
+		deck.Cards = append(deck.Cards, game.Card{Rank: rank})
+	}
+	return game.StartRound(deck)
+}
+
+func TestChartChoose(t *testing.T) {
+	c := Textbook()
+	tests := []struct {
+		name  string
+		cards []int
+		hits  int
+		want  int
+	}{
+		{"8,8 v T", []int{8, 8, 10, 7}, 0, sim.ActionSplit},
+		{"K,6 v T", []int{13, 6, 10, 7}, 0, sim.ActionHit},
+		{"5,6 v 6", []int{5, 6, 6, 7}, 0, sim.ActionDouble},
+		{"A,7 v 3", []int{1, 7, 3, 7}, 0, sim.ActionDouble},
+		{"A,3,4 v 3: no double on three cards", []int{1, 3, 3, 7, 4}, 1, sim.ActionStand},
+		{"T,2 v 4", []int{10, 2, 4, 7}, 0, sim.ActionStand},
+	}
+	for _, tt := range tests {
+		gs := stackedRound(append(tt.cards, 9, 9)...)
+		for i := 0; i < tt.hits; i++ {
+			gs.ActionCalc(0b001)
+		}
+		if got := c.Choose(&gs); got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, sim.ActionNames[got], sim.ActionNames[tt.want])
+		}
+	}
+}
+
+func TestFromDataset(t *testing.T) {
+	sdm := sim.CreateSimDataStructure()
+	set := func(up, player, category int, evs map[int]float64) {
+		for action, ev := range evs {
+			sdm.Set(sim.Key{Dealer: up, Player: player, Category: category, Action: action},
+				sim.SimData{ExpectedValue: ev, Trials: 100})
+		}
+	}
+	set(6, 11, 0, map[int]float64{sim.ActionStand: -0.1, sim.ActionHit: 0.3, sim.ActionDouble: 0.6})
+	set(3, 18, 1, map[int]float64{sim.ActionStand: 0.1, sim.ActionHit: 0.05, sim.ActionDouble: 0.15})
+	set(10, 8, 2, map[int]float64{sim.ActionStand: -0.5, sim.ActionHit: -0.45, sim.ActionSplit: -0.4})
+
+	c, m := FromDataset(sdm, sim.Context{}, "test")
+	tests := []struct {
+		name   string
+		got    Code
+		want   Code
+		margin float64
+	}{
+		{"11 v 6", c.Hard[11][6], Code{sim.ActionDouble, sim.ActionHit}, m.Hard[11][6]},
+		{"A,7 v 3", c.Soft[18][3], Code{sim.ActionDouble, sim.ActionStand}, m.Soft[18][3]},
+		{"8,8 v T", c.Pairs[8][10], NewCode(sim.ActionSplit), m.Pairs[8][10]},
+	}
+	margins := []float64{0.3, 0.05, 0.05}
+	for i, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, tt.got, tt.want)
+		}
+		if math.Abs(tt.margin-margins[i]) > 1e-12 {
+			t.Errorf("%s: margin %g, want %g", tt.name, tt.margin, margins[i])
+		}
+	}
+	// entries without trials keep the defaults, with no margin
+	if c.Hard[16][10] != NewCode(sim.ActionHit) || !math.IsNaN(m.Hard[16][10]) {
+		t.Errorf("16 v T without trials: %s, margin %g", c.Hard[16][10], m.Hard[16][10])
+	}
+}
@@ -0,0 +1,97 @@
+package strategy
+
+import (
+	"blackjack/sim"
+	"math"
+)
+
+// Margins - EV gap between the best and second best action of every chart entry
+// (same indexing as Chart). NaN where the dataset has no trials for the entry.
+// The P arrays hold the p-value of the best action not beating the runner-up
+// (see sim.Significance), NaN where it is unknown.
+type Margins struct {
+	Hard  [22][11]float64
+	Soft  [22][11]float64
+	Pairs [11][11]float64
+
+	PHard  [22][11]float64
+	PSoft  [22][11]float64
+	PPairs [11][11]float64
+}
+
+// FromDataset reads the best action of every chart entry from the first
+// decisions of a dataset - the same choice node_explore makes. ctx picks original
+// (sim.Context{}) or post-split hands. Doubles fall back to the better of hit and
+// stand. Entries the dataset has not reached keep the NewChart defaults.
+func FromDataset(sdm sim.SimDataMap, ctx sim.Context, name string) (Chart, Margins) {
+	c := NewChart(name)
+	var m Margins
+	for up := 1; up <= 10; up++ {
+		for t := range m.Hard {
+			m.Hard[t][up], m.Soft[t][up] = math.NaN(), math.NaN()
+			m.PHard[t][up], m.PSoft[t][up] = math.NaN(), math.NaN()
+		}
+		for x := range m.Pairs {
+			m.Pairs[x][up], m.PPairs[x][up] = math.NaN(), math.NaN()
+		}
+	}
+
+	read := func(code *Code, margin, pValue *float64, up, player, category int) bool {
+		cell := sdm.Cell(ctx, up, player, category)
+		if cell == nil {
+			return false
+		}
+		best, ok := cell.Best()
+		if !ok {
+			return false
+		}
+		*code = NewCode(best)
+		if best != sim.ActionStand && best != sim.ActionHit {
+			code.Fallback = betterOf(cell, sim.ActionStand, sim.ActionHit)
+		}
+		*margin = bestMargin(cell, best)
+		if sig := cell.Significance(); sig.Known {
+			*pValue = sig.PValue
+		}
+		return true
+	}
+
+	var pairsSet [11]bool
+	for up := 1; up <= 10; up++ {
+		for t := firstHard; t <= lastHard; t++ {
+			read(&c.Hard[t][up], &m.Hard[t][up], &m.PHard[t][up], up, t, 0)
+		}
+		for s := firstSoft; s <= lastSoft; s++ {
+			read(&c.Soft[s][up], &m.Soft[s][up], &m.PSoft[s][up], up, s, 1)
+		}
+		for x := 1; x <= 10; x++ {
+			if read(&c.Pairs[x][up], &m.Pairs[x][up], &m.PPairs[x][up], up, x, 2) {
+				pairsSet[x] = true
+			}
+		}
+	}
+	c.fillPairs(pairsSet)
+	return c, m
+}
+
+// betterOf returns whichever of two actions has the higher EV in the cell
+func betterOf(cell *sim.Cell, a, b int) int {
+	if cell.Data[b].Trials > 0 && (cell.Data[a].Trials == 0 || cell.Data[b].ExpectedValue > cell.Data[a].ExpectedValue) {
+		return b
+	}
+	return a
+}
+
+// bestMargin returns the EV gap between best and the next best action with trials
+func bestMargin(cell *sim.Cell, best int) float64 {
+	margin := math.Inf(1)
+	for action, sd := range cell.Data {
+		if action == best || !cell.Has(action) || sd.Trials == 0 {
+			continue
+		}
+		if gap := cell.Data[best].ExpectedValue - sd.ExpectedValue; gap < margin {
+			margin = gap
+		}
+	}
+	return margin
+}
@@ -0,0 +1,77 @@
+package strategy
+
+import "blackjack/sim"
+
+// Disagreement - chart entry where the dataset's best action differs from a chart
+type Disagreement struct {
+	Hand     string // row label e.g. "16", "A,7", "8,8"
+	Category int    // dataset hand category
+	Up       int    // dealer upcard
+
+	Dataset     int // best action in the dataset
+	Reference   int // action of the chart (its fallback when the dataset has no trials for it)
+	DatasetEV   float64
+	ReferenceEV float64
+	Trials      int // trials of the dataset's best action
+
+	// dataset action against the chart action: a small p-value means the
+	// dataset disagrees beyond its noise
+	Significance sim.Significance
+}
+
+// Diff compares the best first decision of every chart entry the dataset has
+// reached with the chart, on original or post-split hands (ctx). It returns the
+// disagreements in chart order and the number of entries compared. Only the
+// action is compared, not the fallback.
+func Diff(sdm sim.SimDataMap, ctx sim.Context, ref Chart) (diffs []Disagreement, compared int) {
+	check := func(code Code, label string, up, player, category int) {
+		cell := sdm.Cell(ctx, up, player, category)
+		if cell == nil {
+			return
+		}
+		best, ok := cell.Best()
+		if !ok {
+			return
+		}
+		action := code.Action
+		if !cell.Has(action) || cell.Data[action].Trials == 0 {
+			action = code.Fallback
+		}
+		if !cell.Has(action) || cell.Data[action].Trials == 0 {
+			return // e.g. surrender in a dataset without it
+		}
+		compared++
+		if action == best {
+			return
+		}
+		diffs = append(diffs, Disagreement{
+			Hand:         label,
+			Category:     category,
+			Up:           up,
+			Dataset:      best,
+			Reference:    action,
+			DatasetEV:    cell.Data[best].ExpectedValue,
+			ReferenceEV:  cell.Data[action].ExpectedValue,
+			Trials:       cell.Data[best].Trials,
+			Significance: cell.Versus(best, action),
+		})
+	}
+
+	// same rows and dataset keys as FromDataset
+	for t := firstHard; t <= lastHard; t++ {
+		for _, up := range chartUps {
+			check(ref.Hard[t][up], sim.HandLabel(t, 0), up, t, 0)
+		}
+	}
+	for s := firstSoft; s <= lastSoft; s++ {
+		for _, up := range chartUps {
+			check(ref.Soft[s][up], softLabel(s), up, s, 1)
+		}
+	}
+	for x := 1; x <= 10; x++ {
+		for _, up := range chartUps {
+			check(ref.Pairs[x][up], pairLabel(x), up, x, 2)
+		}
+	}
+	return diffs, compared
+}
@@ -0,0 +1,47 @@
+package strategy
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"testing"
+)
+
+// record returns action data with the standard error se
+func record(ev, se float64, trials int) sim.SimData {
+	return sim.SimData{ExpectedValue: ev, Trials: trials, VarTrials: trials,
+		M2: se * se * float64(trials) * float64(trials-1)}
+}
+
+func TestDiff(t *testing.T) {
+	sdm := sim.CreateSimDataStructure()
+	set := func(ctx sim.Context, up, player, category int, evs map[int]float64) {
+		for action, ev := range evs {
+			sdm.Set(sim.Key{Context: ctx, Dealer: up, Player: player, Category: category, Action: action},
+				record(ev, 0.005, 10000))
+		}
+	}
+	first, split := sim.Context{}, sim.Context{Split: true}
+	set(first, 10, 16, 0, map[int]float64{sim.ActionStand: -0.50, sim.ActionHit: -0.54}) // planted: the chart hits
+	set(first, 6, 11, 0, map[int]float64{sim.ActionHit: 0.3, sim.ActionDouble: 0.6})
+	set(first, 10, 8, 2, map[int]float64{sim.ActionStand: -0.5, sim.ActionHit: -0.45}) // no split trials: the fallback
+	set(split, 6, 21, 1, map[int]float64{sim.ActionStand: 0.7, sim.ActionHit: 0.1})
+
+	ref := ReferenceFor(game.DefaultRules())
+	diffs, compared := Diff(sdm, first, ref)
+	if compared != 3 || len(diffs) != 1 {
+		t.Fatalf("%d disagreements in %d entries, want 1 in 3: %+v", len(diffs), compared, diffs)
+	}
+	d := diffs[0]
+	if d.Hand != "16" || d.Up != 10 || d.Dataset != sim.ActionStand || d.Reference != sim.ActionHit ||
+		d.DatasetEV != -0.50 || d.ReferenceEV != -0.54 || d.Trials != 10000 {
+		t.Errorf("disagreement %+v", d)
+	}
+	if d.Significance.Margin <= 0 || d.Significance.PValue > 0.01 {
+		t.Errorf("a 0.04 gap over standard errors of 0.005 is not significant: %+v", d.Significance)
+	}
+
+	// split hands dealt A,T are soft 21
+	if diffs, compared := Diff(sdm, split, ref); compared != 1 || len(diffs) != 0 {
+		t.Errorf("split hands: %d disagreements in %d entries, want 0 in 1", len(diffs), compared)
+	}
+}
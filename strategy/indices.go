@@ -0,0 +1,215 @@
+package strategy
+
+import (
+	"blackjack/sim"
+	"fmt"
+	"math"
+)
+
+// Play - a count based deviation from basic strategy on the first decision of an
+// original hand: Deviation is played at and above the index, Basic below it
+type Play struct {
+	Hand      string // e.g. "16", "T,T" or "Insurance"
+	Up        int
+	Player    int // dataset player key
+	Category  int
+	Deviation int
+	Basic     int
+	Insurance bool // the insurance side bet: take it at and above the index
+
+	Listed    bool // Published holds a published index
+	Published int  // published Hi-Lo index (multi deck)
+}
+
+func (p Play) String() string {
+	if p.Insurance {
+		return "Insurance v A"
+	}
+	return fmt.Sprintf("%s v %s %s", p.Hand, sim.UpLabel(p.Up), sim.ActionNames[p.Deviation])
+}
+
+func hardPlay(total, up, deviation, basic, index int) Play {
+	return Play{Hand: sim.HandLabel(total, 0), Up: up, Player: total, Category: 0,
+		Deviation: deviation, Basic: basic, Listed: true, Published: index}
+}
+
+// Illustrious18 - the 18 count deviations worth most to a Hi-Lo player
+var Illustrious18 = []Play{
+	{Hand: "Insurance", Up: 1, Insurance: true, Listed: true, Published: 3},
+	hardPlay(16, 10, sim.ActionStand, sim.ActionHit, 0),
+	hardPlay(15, 10, sim.ActionStand, sim.ActionHit, 4),
+	{Hand: "T,T", Up: 5, Player: 10, Category: 2, Deviation: sim.ActionSplit, Basic: sim.ActionStand, Listed: true, Published: 5},
+	{Hand: "T,T", Up: 6, Player: 10, Category: 2, Deviation: sim.ActionSplit, Basic: sim.ActionStand, Listed: true, Published: 4},
+	hardPlay(10, 10, sim.ActionDouble, sim.ActionHit, 4),
+	hardPlay(12, 3, sim.ActionStand, sim.ActionHit, 2),
+	hardPlay(12, 2, sim.ActionStand, sim.ActionHit, 3),
+	hardPlay(11, 1, sim.ActionDouble, sim.ActionHit, 1),
+	hardPlay(9, 2, sim.ActionDouble, sim.ActionHit, 1),
+	hardPlay(10, 1, sim.ActionDouble, sim.ActionHit, 4),
+	hardPlay(9, 7, sim.ActionDouble, sim.ActionHit, 3),
+	hardPlay(16, 9, sim.ActionStand, sim.ActionHit, 5),
+	hardPlay(13, 2, sim.ActionStand, sim.ActionHit, -1),
+	hardPlay(12, 4, sim.ActionStand, sim.ActionHit, 0),
+	hardPlay(12, 5, sim.ActionStand, sim.ActionHit, -2),
+	hardPlay(12, 6, sim.ActionStand, sim.ActionHit, -1),
+	hardPlay(13, 3, sim.ActionStand, sim.ActionHit, -2),
+}
+
+// Fab4 - the four Hi-Lo surrender deviations
+var Fab4 = []Play{
+	hardPlay(14, 10, sim.ActionSurrender, sim.ActionHit, 3),
+	hardPlay(15, 10, sim.ActionSurrender, sim.ActionHit, 0),
+	hardPlay(15, 9, sim.ActionSurrender, sim.ActionHit, 2),
+	hardPlay(15, 1, sim.ActionSurrender, sim.ActionHit, 1),
+}
+
+// Index - the true count at which a play flips, fitted from a counted dataset
+type Index struct {
+	Play
+	Crossing float64 // true count at which the EV gap Deviation - Basic crosses 0
+	Index    int     // Crossing rounded
+	Slope    float64 // EV gap per true count
+	Buckets  int     // true count buckets in the fit
+	Known    bool
+	Reason   string // why the index is not known
+}
+
+// gapPoint - EV gap of a play in one true count bucket
+type gapPoint struct {
+	tc, gap, se float64
+}
+
+// IndexOf fits the index of a play: a weighted least squares line of the EV gap
+// (Deviation - Basic, paired on the same deals where recorded) over the true
+// count, through the middle of each bucket. The edge buckets hold every count
+// beyond the range, so they are left out.
+func IndexOf(sdm sim.SimDataMap, p Play) Index {
+	idx := Index{Play: p}
+	r := sdm.CountRange()
+	if r == 0 {
+		idx.Reason = "dataset is not counted"
+		return idx
+	}
+
+	var points []gapPoint
+	for count := -r + 1; count < r; count++ {
+		if p.Insurance {
+			if sd := sdm.Insurance(count); sd.Trials > 0 {
+				if se, ok := sd.StdErr(); ok && se > 0 {
+					points = append(points, gapPoint{float64(count) + 0.5, sd.ExpectedValue, se})
+				}
+			}
+			continue
+		}
+		cell := sdm.Cell(sim.Context{Count: count}, p.Up, p.Player, p.Category)
+		if cell == nil || !cell.Has(p.Deviation) || !cell.Has(p.Basic) {
+			continue
+		}
+		if sig := cell.Versus(p.Deviation, p.Basic); sig.Known && sig.StdErr > 0 {
+			points = append(points, gapPoint{float64(count) + 0.5, sig.Margin, sig.StdErr})
+		}
+	}
+	idx.Buckets = len(points)
+	if len(points) < 3 {
+		if cell := sdm.Pooled().Cell(sim.Context{}, p.Up, p.Player, p.Category); !p.Insurance && (cell == nil || !cell.Has(p.Deviation)) {
+			idx.Reason = fmt.Sprintf("no %s records", sim.ActionNames[p.Deviation])
+		} else {
+			idx.Reason = "too few trials"
+		}
+		return idx
+	}
+
+	// weighted least squares gap = a + b*tc
+	var sw, sx, sy, sxx, sxy float64
+	for _, pt := range points {
+		w := 1 / (pt.se * pt.se)
+		sw += w
+		sx += w * pt.tc
+		sy += w * pt.gap
+		sxx += w * pt.tc * pt.tc
+		sxy += w * pt.tc * pt.gap
+	}
+	det := sw*sxx - sx*sx
+	if det <= 0 {
+		idx.Reason = "too few trials"
+		return idx
+	}
+	b := (sw*sxy - sx*sy) / det
+	a := (sy - b*sx) / sw
+	slopeSE := math.Sqrt(sw / det)
+	idx.Slope = b
+	if b <= 2*slopeSE {
+		idx.Reason = "no clear trend with the count"
+		return idx
+	}
+
+	idx.Crossing = -a / b
+	idx.Index = int(math.Round(idx.Crossing))
+	if math.Abs(idx.Crossing) > float64(r) {
+		idx.Reason = fmt.Sprintf("crosses at %+.1f, beyond the recorded counts", idx.Crossing)
+		return idx
+	}
+	idx.Known = true
+	return idx
+}
+
+// Indices fits the index of every play
+func Indices(sdm sim.SimDataMap, plays []Play) []Index {
+	out := make([]Index, len(plays))
+	for i, p := range plays {
+		out[i] = IndexOf(sdm, p)
+	}
+	return out
+}
+
+// Deviations searches every chart entry of a counted dataset for plays that flip
+// within the recorded counts: each action against the best action over all
+// counts. The insurance index comes first.
+func Deviations(sdm sim.SimDataMap) []Index {
+	pooled := sdm.Pooled()
+	out := []Index{IndexOf(sdm, Illustrious18[0])}
+
+	check := func(label string, up, player, category int) {
+		cell := pooled.Cell(sim.Context{}, up, player, category)
+		if cell == nil {
+			return
+		}
+		basic, ok := cell.Best()
+		if !ok {
+			return
+		}
+		for action, sd := range cell.Data {
+			if action == basic || !cell.Has(action) || sd.Trials == 0 {
+				continue
+			}
+			p := Play{Hand: label, Up: up, Player: player, Category: category, Deviation: action, Basic: basic}
+			idx := IndexOf(sdm, p)
+			if !idx.Known && idx.Slope < 0 {
+				// the other action takes over at low counts: basic at and above the index
+				p.Deviation, p.Basic = basic, action
+				idx = IndexOf(sdm, p)
+			}
+			if idx.Known {
+				out = append(out, idx)
+			}
+		}
+	}
+
+	// same rows and dataset keys as FromDataset
+	for t := firstHard; t <= lastHard; t++ {
+		for _, up := range chartUps {
+			check(sim.HandLabel(t, 0), up, t, 0)
+		}
+	}
+	for s := firstSoft; s <= lastSoft; s++ {
+		for _, up := range chartUps {
+			check(softLabel(s), up, s, 1)
+		}
+	}
+	for x := 1; x <= 10; x++ {
+		for _, up := range chartUps {
+			check(pairLabel(x), up, x, 2)
+		}
+	}
+	return out
+}
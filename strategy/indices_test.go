@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"blackjack/sim"
+	"math"
+	"testing"
+)
+
+// countedDataset records 16 v T with standing gaining 0.02 per true count on
+// hitting, even at +1, and insurance breaking even at +3
+func countedDataset() sim.SimDataMap {
+	sdm := sim.CreateCountedStructure(6)
+	for count := -6; count <= 6; count++ {
+		tc := float64(count) + 0.5
+		ctx := sim.Context{Count: count}
+		sdm.Set(sim.Key{Context: ctx, Dealer: 10, Player: 16, Action: sim.ActionHit}, record(-0.54, 0.002, 100000))
+		sdm.Set(sim.Key{Context: ctx, Dealer: 10, Player: 16, Action: sim.ActionStand}, record(-0.54+0.02*(tc-1), 0.002, 100000))
+		*sdm.Insurance(count) = record(0.05*(tc-3), 0.005, 10000)
+	}
+	return sdm
+}
+
+func TestIndexOf(t *testing.T) {
+	sdm := countedDataset()
+	for _, p := range Illustrious18[:2] {
+		idx := IndexOf(sdm, p)
+		want := 1.0
+		if p.Insurance {
+			want = 3
+		}
+		if !idx.Known || math.Abs(idx.Crossing-want) > 1e-9 || idx.Index != int(want) || idx.Buckets != 11 {
+			t.Errorf("%s: %+v, want the index %g over 11 buckets", p, idx, want)
+		}
+	}
+	if idx := IndexOf(sdm, Illustrious18[1]); math.Abs(idx.Slope-0.02) > 1e-9 {
+		t.Errorf("16 v T slope %g, want 0.02", idx.Slope)
+	}
+
+	tests := []struct {
+		name   string
+		sdm    sim.SimDataMap
+		play   Play
+		reason string
+	}{
+		{"not counted", sim.CreateSimDataStructure(), Illustrious18[1], "dataset is not counted"},
+		{"no surrender", sdm, Fab4[0], "no Surrender records"},
+		{"no trials", sdm, Illustrious18[2], "too few trials"},
+		{"wrong way round", sdm, hardPlay(16, 10, sim.ActionHit, sim.ActionStand, 0), "no clear trend with the count"},
+	}
+	for _, tt := range tests {
+		if idx := IndexOf(tt.sdm, tt.play); idx.Known || idx.Reason != tt.reason {
+			t.Errorf("%s: known %v, reason %q, want %q", tt.name, idx.Known, idx.Reason, tt.reason)
+		}
+	}
+}
+
+func TestDeviations(t *testing.T) {
+	out := Deviations(countedDataset())
+	if len(out) != 2 || !out[0].Insurance || !out[0].Known {
+		t.Fatalf("deviations %+v, want insurance and 16 v T", out)
+	}
+	// hitting is the better play over all counts, standing takes over at +1
+	if d := out[1]; d.Hand != "16" || d.Up != 10 || d.Deviation != sim.ActionStand || d.Basic != sim.ActionHit || d.Index != 1 {
+		t.Errorf("16 v T deviation %+v", d)
+	}
+}
@@ -0,0 +1,75 @@
+package strategy
+
+import (
+	"blackjack/game"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Published basic strategy charts in the plain text layout, one per deck count,
+// H17/S17 and DAS/no DAS. They assume the dealer peeks for blackjack and no
+// surrender. 6 and 8 decks share one strategy under these rules.
+//
+//go:embed reference/*.txt
+var referenceFiles embed.FS
+
+// referenceDecks - deck counts with a reference chart
+var referenceDecks = []int{1, 2, 6, 8}
+
+// ReferenceName returns the name of the reference chart for rules, e.g.
+// "6d-h17-das". Shoes between the charted deck counts use the nearest larger
+// one (3-6 decks: 6), larger shoes and infinite decks (0) the 8 deck chart.
+func ReferenceName(rules game.Rules) string {
+	decks := referenceDecks[len(referenceDecks)-1]
+	for _, d := range referenceDecks {
+		if rules.Decks > 0 && rules.Decks <= d {
+			decks = d
+			break
+		}
+	}
+	soft17, das := "s17", "das"
+	if rules.DealerHitsSoft17 {
+		soft17 = "h17"
+	}
+	if !rules.DoubleAfterSplit {
+		das = "nodas"
+	}
+	return fmt.Sprintf("%dd-%s-%s", decks, soft17, das)
+}
+
+// ReferenceNames lists the embedded reference charts
+func ReferenceNames() []string {
+	files, _ := fs.Glob(referenceFiles, "reference/*.txt")
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = strings.TrimSuffix(strings.TrimPrefix(f, "reference/"), ".txt")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reference returns an embedded reference chart by name (see ReferenceNames)
+func Reference(name string) (Chart, error) {
+	file, err := referenceFiles.Open("reference/" + name + ".txt")
+	if err != nil {
+		return Chart{}, fmt.Errorf("no reference chart %q (have %s)", name, strings.Join(ReferenceNames(), ", "))
+	}
+	defer file.Close()
+	c, err := ParseChart(file)
+	if err != nil {
+		panic("Error: invalid reference chart " + name + ": " + err.Error())
+	}
+	return c, nil
+}
+
+// ReferenceFor returns the reference chart matching rules
+func ReferenceFor(rules game.Rules) Chart {
+	c, err := Reference(ReferenceName(rules))
+	if err != nil {
+		panic("Error: " + err.Error())
+	}
+	return c
+}
@@ -0,0 +1,65 @@
+package strategy
+
+import (
+	"blackjack/game"
+	"blackjack/sim"
+	"testing"
+)
+
+func TestReferenceName(t *testing.T) {
+	tests := []struct {
+		decks    int
+		h17, das bool
+		want     string
+	}{
+		{1, false, true, "1d-s17-das"},
+		{2, true, true, "2d-h17-das"},
+		{4, true, false, "6d-h17-nodas"},
+		{8, false, true, "8d-s17-das"},
+		{12, false, false, "8d-s17-nodas"},
+		{0, true, true, "8d-h17-das"}, // infinite deck
+	}
+	for _, tt := range tests {
+		rules := game.Rules{Decks: tt.decks, DealerHitsSoft17: tt.h17, DoubleAfterSplit: tt.das}
+		if got := ReferenceName(rules); got != tt.want {
+			t.Errorf("ReferenceName(%s) = %q, want %q", rules, got, tt.want)
+		}
+	}
+}
+
+func TestReference(t *testing.T) {
+	names := ReferenceNames()
+	if len(names) != 16 {
+		t.Errorf("%d reference charts, want 16: %v", len(names), names)
+	}
+	// every rule combination has a chart
+	for _, decks := range referenceDecks {
+		for _, h17 := range []bool{false, true} {
+			for _, das := range []bool{false, true} {
+				rules := game.Rules{Decks: decks, DealerHitsSoft17: h17, DoubleAfterSplit: das}
+				if _, err := Reference(ReferenceName(rules)); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}
+	if _, err := Reference("3d-s17-das"); err == nil {
+		t.Errorf("no error for a missing chart")
+	}
+
+	c := ReferenceFor(game.DefaultRules())
+	for _, e := range []struct {
+		name string
+		got  Code
+		want int
+	}{
+		{"16 v T", c.Hard[16][10], sim.ActionHit},
+		{"11 v A", c.Hard[11][1], sim.ActionDouble},
+		{"A,8 v 6", c.Soft[19][6], sim.ActionDouble},
+		{"9,9 v 7", c.Pairs[9][7], sim.ActionStand},
+	} {
+		if e.got.Action != e.want {
+			t.Errorf("1 deck S17 DAS %s: %s, want %s", e.name, e.got, sim.ActionNames[e.want])
+		}
+	}
+}
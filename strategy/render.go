@@ -0,0 +1,216 @@
+package strategy
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format - output format for Render
+type Format int
+
+const (
+	FormatText     Format = iota // plain text layout (readable by ParseChart when unmarked)
+	FormatMarkdown               // Markdown tables
+	FormatANSI                   // coloured terminal output
+)
+
+// ParseFormat reads a format name: "text", "markdown" / "md" or "ansi"
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text", "txt", "plain":
+		return FormatText, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "ansi", "color", "colour":
+		return FormatANSI, nil
+	}
+	return 0, fmt.Errorf("unknown chart format %q", s)
+}
+
+// background / foreground colour per action code letter
+var ansiColours = map[string]string{
+	"S": "\x1b[30;43m",  // black on yellow
+	"H": "\x1b[97;41m",  // white on red
+	"D": "\x1b[30;42m",  // black on green
+	"P": "\x1b[30;46m",  // black on cyan
+	"R": "\x1b[97;100m", // white on grey
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiDim   = "\x1b[2m"
+)
+
+// chartSection - one block of rows (hard, soft or pairs)
+type chartSection struct {
+	title   string
+	labels  []string
+	codes   [][11]Code
+	margins [][11]float64 // nil without margins
+	pValues [][11]float64
+}
+
+func (c Chart) sections(m *Margins) []chartSection {
+	hard := chartSection{title: "Hard"}
+	for t := 5; t <= 20; t++ {
+		hard.labels = append(hard.labels, strconv.Itoa(t))
+		hard.codes = append(hard.codes, c.Hard[t])
+	}
+	soft := chartSection{title: "Soft"}
+	for s := 13; s <= 20; s++ {
+		soft.labels = append(soft.labels, softLabel(s))
+		soft.codes = append(soft.codes, c.Soft[s])
+	}
+	pairs := chartSection{title: "Pair"}
+	for x := 1; x <= 10; x++ {
+		pairs.labels = append(pairs.labels, pairLabel(x))
+		pairs.codes = append(pairs.codes, c.Pairs[x])
+	}
+
+	if m != nil {
+		hard.margins = append(hard.margins, m.Hard[5:21]...)
+		soft.margins = append(soft.margins, m.Soft[13:21]...)
+		pairs.margins = append(pairs.margins, m.Pairs[1:11]...)
+		hard.pValues = append(hard.pValues, m.PHard[5:21]...)
+		soft.pValues = append(soft.pValues, m.PSoft[13:21]...)
+		pairs.pValues = append(pairs.pValues, m.PPairs[1:11]...)
+	}
+	return []chartSection{hard, soft, pairs}
+}
+
+// Render writes the chart in the given format. With margins, entries the dataset
+// has not reached show as "?", entries whose best action is not significantly
+// better than the runner-up at level alpha are marked with "~" and other entries
+// whose best action leads the next best by less than tiny EV with "*".
+func (c Chart) Render(w io.Writer, format Format, m *Margins, tiny, alpha float64) error {
+	var sb strings.Builder
+
+	// entry text, its mark ("", "~" or "*") and whether it is unknown
+	entry := func(sec chartSection, row, up int) (code, mark string, unknown bool) {
+		code = sec.codes[row][up].String()
+		if sec.margins == nil {
+			return code, "", false
+		}
+		margin := sec.margins[row][up]
+		if math.IsNaN(margin) {
+			return "?", "", true
+		}
+		switch p := sec.pValues[row][up]; {
+		case alpha > 0 && (math.IsNaN(p) || p >= alpha):
+			mark = "~"
+		case margin < tiny:
+			mark = "*"
+		}
+		return code, mark, false
+	}
+	marking := m != nil && (tiny > 0 || alpha > 0)
+
+	switch format {
+	case FormatMarkdown:
+		if c.Name != "" {
+			sb.WriteString("### " + c.Name + "\n\n")
+		}
+		for _, sec := range c.sections(m) {
+			sb.WriteString("| " + sec.title + " |")
+			for _, up := range chartUps {
+				sb.WriteString(" " + upLabel(up) + " |")
+			}
+			sb.WriteString("\n|---|" + strings.Repeat(":-:|", len(chartUps)) + "\n")
+			for row, label := range sec.labels {
+				sb.WriteString("| " + label + " |")
+				for _, up := range chartUps {
+					code, mark, _ := entry(sec, row, up)
+					if mark == "*" {
+						mark = `\*`
+					}
+					code += mark
+					sb.WriteString(" " + code + " |")
+				}
+				sb.WriteString("\n")
+			}
+			sb.WriteString("\n")
+		}
+
+	case FormatANSI:
+		if c.Name != "" {
+			sb.WriteString(c.Name + "\n")
+		}
+		for i, sec := range c.sections(m) {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(fmt.Sprintf("%-5s", sec.title))
+			for _, up := range chartUps {
+				sb.WriteString(fmt.Sprintf(" %-3s", upLabel(up)))
+			}
+			sb.WriteString("\n")
+			for row, label := range sec.labels {
+				sb.WriteString(fmt.Sprintf("%-5s", label))
+				for _, up := range chartUps {
+					code, mark, unknown := entry(sec, row, up)
+					text := fmt.Sprintf("%-2s%-1s", code, mark)
+					if unknown {
+						sb.WriteString(" " + ansiDim + text + ansiReset)
+					} else {
+						sb.WriteString(" " + ansiColours[code[:1]] + text + ansiReset)
+					}
+				}
+				sb.WriteString("\n")
+			}
+		}
+
+	default:
+		// room for the mark after two letter codes
+		column := "  %-2s"
+		if marking {
+			column = "  %-3s"
+		}
+		if c.Name != "" {
+			sb.WriteString("# " + c.Name + "\n")
+		}
+		for i, sec := range c.sections(m) {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			line := fmt.Sprintf("%-5s", sec.title)
+			for _, up := range chartUps {
+				line += fmt.Sprintf(column, upLabel(up))
+			}
+			sb.WriteString(strings.TrimRight(line, " ") + "\n")
+			for row, label := range sec.labels {
+				line := fmt.Sprintf("%-5s", label)
+				for _, up := range chartUps {
+					code, mark, _ := entry(sec, row, up)
+					line += fmt.Sprintf(column, code+mark)
+				}
+				sb.WriteString(strings.TrimRight(line, " ") + "\n")
+			}
+		}
+	}
+
+	if marking {
+		var notes []string
+		if alpha > 0 {
+			notes = append(notes, fmt.Sprintf("~ best action not significantly better than the runner-up (p >= %g)", alpha))
+		}
+		if tiny > 0 {
+			notes = append(notes, fmt.Sprintf("* best action leads the next best by less than %.4f EV", tiny))
+		}
+		sb.WriteString("\n")
+		for _, note := range notes {
+			switch format {
+			case FormatMarkdown:
+				note = `\` + note + "\n"
+			case FormatText:
+				note = "# " + note
+			}
+			sb.WriteString(note + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,14 @@
+package strategy
+
+// textbookReference - the embedded chart of the standard 4-8 deck, S17, DAS basic strategy
+const textbookReference = "6d-s17-das"
+
+// Textbook returns the standard multi deck S17 DAS basic strategy chart
+func Textbook() Chart {
+	c, err := Reference(textbookReference)
+	if err != nil {
+		panic("Error: " + err.Error())
+	}
+	c.Name = "textbook (4-8 decks, S17, DAS)"
+	return c
+}